@@ -4,17 +4,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ncruces/zenity"
 )
 
+// folderChoice is a folder picked in the open dialog.
+type folderChoice struct {
+	path     string
+	readOnly bool
+}
+
 // promptOpenFolder launches the OS folder picker via zenity in a goroutine.
 // The result is delivered through openFolderCh so the frame loop can pick it up.
-func (a *App) promptOpenFolder() {
+func (a *App) promptOpenFolder(readOnly bool) {
+	title := "Open Folder"
+	if readOnly {
+		title = "Open Folder Read-Only"
+	}
 	go func() {
 		path, err := zenity.SelectFile(
-			zenity.Title("Open Folder"),
+			zenity.Title(title),
 			zenity.Directory(),
 		)
 		if err != nil {
@@ -22,35 +34,65 @@ func (a *App) promptOpenFolder() {
 			return
 		}
 		if path != "" {
-			a.openFolderCh <- path
+			a.openFolderCh <- folderChoice{path: path, readOnly: readOnly}
 			a.window.Invalidate()
 		}
 	}()
 }
 
-// openFolder sets rootPath and resets the file tree.
-func (a *App) openFolder(path string) {
+// openFolder sets rootPath and resets the file tree. A read-only folder
+// (or every folder, with Config.ReadOnly) can be browsed but not changed.
+func (a *App) openFolder(path string, readOnly bool) {
 	a.rootPath = path
+	a.loadFolderConfig(path)
+	a.readOnly = readOnly || a.cfg.ReadOnly
+	a.loadIgnore(path)
+	a.gitStates = nil
+	a.refreshGitStatus()
 	a.currentFile = ""
 	a.modified = false
 
-	a.loading = true
 	a.editor.SetText("")
-	a.loading = false
+	a.folds = nil
+	a.savedText = ""
+	a.savedAt = time.Time{}
 
 	a.previewBlocks = nil
-	a.fileTree.Reset()
+	a.fileTree.Restore()
 
-	a.status = "Folder: " + path
+	a.status = "Folder: " + path + a.readOnlyTag()
 	a.updateTitle()
 }
 
-// confirmSwitch opens targetPath, prompting about unsaved changes if needed.
+// denyReadOnly reports whether the open folder is read-only, saying so in
+// the status bar. Commands that change files or the buffer check it first.
+func (a *App) denyReadOnly() bool {
+	if a.readOnly {
+		a.status = "Read-only folder: editing is disabled"
+	}
+	return a.readOnly
+}
+
+// confirmSwitch opens targetPath, handling unsaved changes according to the
+// OnSwitch setting (by default, prompting).
 func (a *App) confirmSwitch(targetPath string) {
 	if !a.modified {
 		a.loadFile(targetPath)
 		return
 	}
+	switch strings.ToLower(a.cfg.OnSwitch) {
+	case "save":
+		a.saveFile()
+		if a.modified {
+			// Save failed; stay on the current file so nothing is lost.
+			return
+		}
+		a.loadFile(targetPath)
+		return
+	case "discard":
+		a.loadFile(targetPath)
+		return
+	}
 	prev := a.currentFile
 	a.showConfirmModal(
 		"Unsaved Changes",
@@ -69,20 +111,115 @@ func (a *App) confirmSwitch(targetPath string) {
 func (a *App) loadFile(path string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		a.status = "Error: " + err.Error()
+		a.notifyError(err)
+		return
+	}
+
+	content, format, err := decodeFile(data)
+	if err != nil {
+		a.notifyError(fmt.Errorf("%s: %w", filepath.Base(path), err))
+		return
+	}
+	if a.currentFile != "" && path != a.currentFile {
+		a.previousFile = a.currentFile
+	}
+	a.showDocument(path, content, format)
+	a.rememberFile(path)
+}
+
+// switchToPrevious reopens the file that was open before the current one, so
+// Ctrl+Tab bounces between two notes.
+func (a *App) switchToPrevious() {
+	if a.previousFile == "" {
+		a.status = "No previous file"
+		return
+	}
+	if _, err := os.Stat(a.previousFile); err != nil {
+		a.status = "Previous file no longer exists: " + filepath.Base(a.previousFile)
+		a.previousFile = ""
+		return
+	}
+	a.revealInTree(a.previousFile)
+	a.confirmSwitch(a.previousFile)
+}
+
+// reopenWithEncoding reloads the current file from disk, decoding it as enc
+// instead of the detected encoding.
+func (a *App) reopenWithEncoding(enc textEncoding) {
+	if a.currentFile == "" {
+		return
+	}
+	data, err := os.ReadFile(a.currentFile)
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	content, format, err := decodeAs(data, enc)
+	if err != nil {
+		a.notifyError(fmt.Errorf("%s: %w", filepath.Base(a.currentFile), err))
+		return
+	}
+	a.showDocument(a.currentFile, content, format)
+	a.status = "Reopened as " + enc.label() + ": " + a.currentFile
+}
+
+// promptReload re-reads the current file from disk, confirming first if that
+// would discard unsaved changes.
+func (a *App) promptReload() {
+	if a.currentFile == "" {
+		return
+	}
+	if !a.modified {
+		a.reloadFile()
 		return
 	}
+	a.showConfirmModal(
+		"Unsaved Changes",
+		"Discard changes to '"+filepath.Base(a.currentFile)+"' and reload it from disk?",
+		a.reloadFile,
+		nil,
+	)
+}
+
+// reloadFile replaces the editor content with the file on disk, keeping the
+// caret where it was as far as the new text allows.
+func (a *App) reloadFile() {
+	if _, err := os.Stat(a.currentFile); err != nil {
+		a.notifyError(err)
+		return
+	}
+	caret, _ := a.editor.Selection()
+	a.loadFile(a.currentFile)
+	caret = min(caret, a.editor.Len())
+	a.editor.SetCaret(caret, caret)
+	a.status = "Reloaded: " + a.currentFile
+}
+
+// showDocument puts decoded file content into the editor and preview.
+func (a *App) showDocument(path, content string, format fileFormat) {
+	// Whatever the old buffer held has been saved or deliberately dropped.
+	removeRecovery(a.currentFile)
 
+	if path != a.currentFile {
+		a.folded, a.foldBtns = nil, nil
+	}
+	a.dropCarets()
 	a.currentFile = path
 	a.selectedPath = path
+	a.format = format
 
-	a.loading = true
-	a.editor.SetText(string(data))
-	a.loading = false
+	a.editor.SetText(content)
+	a.folds = nil
+	a.savedText = content
+	a.savedAt = modTime(path)
 
 	a.modified = false
-	a.previewBlocks = renderMarkdown(string(data))
+	a.previewBlocks = renderMarkdown(content, a.renderOptions())
 	a.updateTitle()
+
+	a.recoveryAt = time.Now()
+	a.recoveryText = content
+	a.offerRecovery(path, content)
 }
 
 // targetDir returns the directory to use for new-file operations.
@@ -101,6 +238,9 @@ func (a *App) targetDir() string {
 
 // promptNewFile shows an input modal asking for a filename then creates the file.
 func (a *App) promptNewFile() {
+	if a.denyReadOnly() {
+		return
+	}
 	dir := a.targetDir()
 	if dir == "" {
 		a.showConfirmModal(
@@ -111,42 +251,362 @@ func (a *App) promptNewFile() {
 		return
 	}
 
-	a.showInputModal("New File", "Enter a filename:", func(name string) {
+	if a.cfg.NewFileName != "" {
+		a.createNewFile(filepath.Join(dir, a.autoFileName(dir)), "")
+		return
+	}
+
+	templates := listTemplates()
+	m := a.showInputModal("New File", "Enter a filename:", nil)
+	if len(templates) > 0 {
+		m.options = append([]string{"Empty"}, templates...)
+	}
+	m.onOK = func(name string) {
 		name = strings.TrimSpace(name)
 		if name == "" {
 			return
 		}
-		if !strings.HasSuffix(strings.ToLower(name), ".md") {
-			name += ".md"
+		path := filepath.Join(dir, a.withNoteExt(name))
+
+		var content string
+		if m.option > 0 {
+			tmpl, err := readTemplate(m.options[m.option])
+			if err != nil {
+				a.notifyError(err)
+				return
+			}
+			content = a.expandTemplate(tmpl, path, time.Now())
 		}
-		a.createNewFile(filepath.Join(dir, name))
-	})
+		a.createNewFile(path, content)
+	}
+}
+
+// newFileExt returns the configured extension for new files, ".md" by default.
+func (a *App) newFileExt() string {
+	ext := strings.TrimSpace(a.cfg.NewFileExtension)
+	if ext == "" {
+		return ".md"
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// withNoteExt adds the new-file extension to name unless it already has a
+// note extension.
+func (a *App) withNoteExt(name string) string {
+	if a.isNoteFile(name) {
+		return name
+	}
+	return name + a.newFileExt()
+}
+
+// autoFileName expands the NewFileName pattern for dir, replacing {n} with
+// the lowest number not already taken.
+func (a *App) autoFileName(dir string) string {
+	pattern := a.cfg.NewFileName
+	if !strings.Contains(pattern, "{n}") {
+		return a.withNoteExt(pattern)
+	}
+	for n := 1; ; n++ {
+		name := a.withNoteExt(strings.ReplaceAll(pattern, "{n}", strconv.Itoa(n)))
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			return name
+		}
+	}
 }
 
-// createNewFile creates a file at path, refreshes the tree, and opens it.
-func (a *App) createNewFile(path string) {
+// createNewFile creates a file at path containing content, refreshes the tree,
+// and opens it (asking first if the current file has unsaved changes).
+func (a *App) createNewFile(path, content string) {
+	if a.denyReadOnly() {
+		return
+	}
 	if _, err := os.Stat(path); err == nil {
-		a.status = fmt.Sprintf("Error: '%s' already exists", filepath.Base(path))
+		a.notifyError(fmt.Errorf("'%s' already exists", filepath.Base(path)))
 		return
 	}
-	if err := os.WriteFile(path, []byte{}, 0644); err != nil {
-		a.status = "Error: " + err.Error()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		a.notifyError(err)
 		return
 	}
 	a.fileTree.Refresh()
-	a.loadFile(path)
+	a.revealInTree(path)
+	a.confirmSwitch(path)
+}
+
+// openDailyNote opens today's journal note, creating it (and its folder) from
+// the journal template if it does not exist yet.
+func (a *App) openDailyNote() {
+	if a.rootPath == "" {
+		a.status = "Open a folder first (Ctrl+O)"
+		return
+	}
+	now := time.Now()
+	rel := now.Format(a.cfg.JournalPath)
+	if filepath.Ext(rel) == "" {
+		rel += ".md"
+	}
+	path := filepath.Join(a.rootPath, filepath.FromSlash(rel))
+
+	if _, err := os.Stat(path); err == nil {
+		a.revealInTree(path)
+		a.confirmSwitch(path)
+		return
+	}
+	// A read-only folder still opens an existing note, but gets no new one
+	// and no journal folder.
+	if a.denyReadOnly() {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.createNewFile(path, a.expandTemplate(a.cfg.JournalTemplate, path, now))
 }
 
 // saveFile writes the editor content to the current file.
 func (a *App) saveFile() {
-	if a.currentFile == "" {
+	a.saveNote(a.cfg.GitAutoCommit)
+}
+
+// saveNote is saveFile, committing the note afterwards if autoCommit is set
+// and it is in a git repository.
+func (a *App) saveNote(autoCommit bool) {
+	if a.currentFile == "" || a.denyReadOnly() {
+		return
+	}
+	if a.holdLossySave(func() { a.saveNote(autoCommit) }) {
+		return
+	}
+	content, ok := a.writeDocument(a.currentFile)
+	if !ok {
+		return
+	}
+	a.savedText = content
+	a.savedAt = modTime(a.currentFile)
+	removeRecovery(a.currentFile)
+	a.recoveryText = content
+	a.modified = false
+	a.updateTitle()
+	if autoCommit && a.gitRoot != "" {
+		rel, _ := filepath.Rel(a.gitRoot, a.currentFile)
+		a.gitCommit(a.currentFile, "Update "+filepath.ToSlash(rel))
+	} else {
+		a.refreshGitStatus()
+	}
+	if strings.EqualFold(a.cfg.PreviewUpdate, "save") {
+		a.previewBlocks = renderMarkdown(content, a.renderOptions())
+	}
+	a.notify("Saved: " + a.currentFile)
+}
+
+// saveOnFocusLoss saves the note as the window loses focus, with
+// Config.SaveOnFocusLoss. A note with nothing unsaved is left alone, so the
+// file is not written again after a save.
+func (a *App) saveOnFocusLoss() {
+	if !a.cfg.SaveOnFocusLoss || a.currentFile == "" || a.readOnly || !a.modified {
+		return
+	}
+	a.saveFile()
+	a.window.Invalidate()
+}
+
+// writeDocument writes the editor's text to path in the note's encoding,
+// first normalizing its whitespace, and returns the text written. It reports
+// false after showing the error.
+func (a *App) writeDocument(path string) (string, bool) {
+	content := a.docText()
+	if normalized := a.normalizeWhitespace(content); normalized != content {
+		a.unfoldAll()
+		a.setTextKeepCaret(content, normalized)
+		content = normalized
+	}
+	data, err := a.encodeFile(content, a.format)
+	if err != nil {
+		a.notifyError(err)
+		return "", false
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		a.notifyError(err)
+		return "", false
+	}
+	return content, true
+}
+
+// holdLossySave reports whether a save must wait because the note was
+// reopened as UTF-8 from bytes that are not valid UTF-8, which saving would
+// replace with U+FFFD. It asks first, and runs save once confirmed.
+func (a *App) holdLossySave(save func()) bool {
+	if !a.format.invalid {
+		return false
+	}
+	if a.modal == nil {
+		m := a.showConfirmModal(
+			"Invalid UTF-8",
+			"'"+filepath.Base(a.currentFile)+"' has bytes that are not valid UTF-8. Saving replaces them with \uFFFD. Save anyway?",
+			func() {
+				a.format.invalid = false
+				save()
+			},
+			nil,
+		)
+		m.okLabel = "Save"
+	}
+	a.status = "Not saved: confirm replacing the invalid UTF-8 first"
+	return true
+}
+
+// savePick is a destination chosen in a save dialog, delivered to the frame
+// loop. targets are the files that write replaces if they exist.
+type savePick struct {
+	targets []string
+	write   func()
+}
+
+// promptSaveAs asks where to save the note with the OS save dialog; the
+// choice arrives through savePickCh.
+func (a *App) promptSaveAs() {
+	if a.currentFile == "" || a.denyReadOnly() {
+		return
+	}
+	current := a.currentFile
+	go func() {
+		out, err := zenity.SelectFileSave(zenity.Title("Save As"), zenity.Filename(current))
+		if err != nil || out == "" {
+			return
+		}
+		pick := savePick{write: func() { a.saveAs(out) }}
+		if out != current {
+			pick.targets = []string{out}
+		}
+		a.savePickCh <- pick
+		a.window.Invalidate()
+	}()
+}
+
+// saveAs writes the note to path and goes on editing it there. The file it
+// was opened from is left as it was last saved.
+func (a *App) saveAs(path string) {
+	if path == a.currentFile {
+		a.saveFile()
 		return
 	}
-	if err := os.WriteFile(a.currentFile, []byte(a.editor.Text()), 0644); err != nil {
-		a.status = "Error: " + err.Error()
+	if a.holdLossySave(func() { a.saveAs(path) }) {
 		return
 	}
+	content, ok := a.writeDocument(path)
+	if !ok {
+		return
+	}
+	removeRecovery(a.currentFile)
+	a.previousFile = a.currentFile
+	a.currentFile = path
+	a.savedText = content
+	a.savedAt = modTime(path)
+	a.recoveryText = content
 	a.modified = false
 	a.updateTitle()
-	a.status = "Saved: " + a.currentFile
+	a.rememberFile(path)
+	a.fileTree.Refresh()
+	a.revealInTree(path)
+	a.refreshGitStatus()
+	a.notify("Saved as: " + path)
+}
+
+// confirmOverwrite runs write, first asking whether to replace those of
+// targets that already exist, unless Config.ConfirmOverwrite is off.
+func (a *App) confirmOverwrite(targets []string, write func()) {
+	var existing []string
+	if a.cfg.ConfirmOverwrite {
+		for _, p := range targets {
+			if _, err := os.Stat(p); err == nil {
+				existing = append(existing, p)
+			}
+		}
+	}
+	var m *modalState
+	switch len(existing) {
+	case 0:
+		write()
+		return
+	case 1:
+		m = a.showConfirmModal("Replace File", "'"+filepath.Base(existing[0])+"' already exists. Replace it?", write, nil)
+	default:
+		m = a.showConfirmModal("Replace Files", fmt.Sprintf("%d of the files to write already exist. Replace them?", len(existing)), write, nil)
+	}
+	m.okLabel = "Replace"
+}
+
+// livePreview reports whether the preview follows every edit.
+func (a *App) livePreview() bool {
+	switch strings.ToLower(a.cfg.PreviewUpdate) {
+	case "save", "manual":
+		return false
+	}
+	return true
+}
+
+// refreshPreview re-renders the preview from the editor, for the "save" and
+// "manual" update modes.
+func (a *App) refreshPreview() {
+	a.previewBlocks = renderMarkdown(a.docText(), a.renderOptions())
+	a.status = "Preview refreshed"
+}
+
+// modTime returns the modification time of path, or the zero time if it
+// cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// normalizeWhitespace applies the on-save clean-ups enabled in the config.
+// Trimming keeps a two-space markdown hard break at the end of a non-blank
+// line.
+func (a *App) normalizeWhitespace(content string) string {
+	if a.cfg.TrimTrailingWhitespace {
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			trimmed := strings.TrimRight(line, " \t")
+			if trimmed != "" && strings.HasSuffix(line, "  ") {
+				trimmed += "  "
+			}
+			lines[i] = trimmed
+		}
+		content = strings.Join(lines, "\n")
+	}
+	if a.cfg.EnsureFinalNewline && content != "" {
+		content = strings.TrimRight(content, "\n") + "\n"
+	}
+	return content
+}
+
+// setTextKeepCaret replaces the editor text with updated, which must differ
+// from old only at line ends or at the end of the text, and moves the
+// selection to the same line and column (clamped) it had in old.
+func (a *App) setTextKeepCaret(old, updated string) {
+	start, end := a.editor.Selection()
+	oldLines := strings.Split(old, "\n")
+	newLines := strings.Split(updated, "\n")
+
+	remap := func(pos int) int {
+		line, col := offsetToLineCol(oldLines, pos)
+		if line >= len(newLines) {
+			return len([]rune(updated))
+		}
+		if n := len([]rune(newLines[line])); col > n {
+			col = n
+		}
+		return lineColToOffset(newLines, line, col)
+	}
+	newStart, newEnd := remap(start), remap(end)
+
+	a.editor.SetText(updated)
+	a.editor.SetCaret(newStart, newEnd)
 }
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"image"
 	"image/color"
+	"maps"
+	"net/url"
+	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"gioui.org/app"
 	"gioui.org/font"
-	"gioui.org/font/gofont"
 	"gioui.org/io/event"
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
@@ -15,7 +23,6 @@ import (
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
-	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
@@ -25,30 +32,83 @@ import (
 type App struct {
 	window app.Window
 	th     *material.Theme
+	cfg    Config
+	// configErr is why the config file did not load, reported once the
+	// window opens.
+	configErr error
+	// savedConfig is the config as this window last loaded or saved it, by
+	// key; saveConfig writes only the keys that differ from it.
+	savedConfig map[string]json.RawMessage
+	// folderGlobals are the global values of the settings the open folder
+	// overrides, by config key (see folderconfig.go).
+	folderGlobals map[string]json.RawMessage
+	// md are the parsers for cfg.MarkdownFlavor, used by the preview and
+	// every export from this window.
+	md *markdownParsers
+	// primary is the first window: it restores the last session and saves
+	// it on close. Ctrl+Shift+N opens further windows (see newWindow).
+	primary bool
 
 	// File state
 	rootPath     string
+	readOnly     bool // rootPath was opened read-only (see denyReadOnly)
 	currentFile  string
+	previousFile string // file open before currentFile, for Ctrl+Tab
+	focused      bool   // the window has focus, as of the last ConfigEvent
+	carets       extraCarets
 	modified     bool
-	loading      bool
+	savedText    string     // editor content as last loaded or saved
+	format       fileFormat // on-disk format of currentFile
 	selectedPath string
+	ignore       *ignoreMatcher // rootPath's ignore patterns (see ignore.go)
+	gitRoot      string         // git work tree containing rootPath, or ""
+	gitignores   map[string]*ignoreMatcher
+
+	// Latest `git status` of gitRoot (see git.go)
+	gitStates   map[string]gitState
+	gitStatusAt time.Time
+	gitPending  bool
+
+	// Last snapshot on the git autosave branch, and when one was last due
+	autosaveAt      time.Time
+	autosaveFile    string
+	autosaveText    string
+	autosavePending bool
 
 	// Widgets
 	editor   widget.Editor
 	fileTree *FileTree
 
-	// Split ratios [0..1]
-	treeSplit   float32
-	editorSplit float32
-	mainWidth   int
+	// Split ratios live in Config (TreeSplit, EditorSplit, StackSplit)
+	mainWidth int
 
 	// Split drag state
 	treeDrag   dragHandle
 	editorDrag dragHandle
+	stackDrag  dragHandle // between stacked panes (Config.StackedLayout)
 
 	// Preview
+	previewTag    struct{} // key focus and pointer tag for the pane
 	previewBlocks []renderedBlock
 	previewList   widget.List
+	previewFade   scrollFade
+
+	// Preview headings folded by a click, by headingKeys key
+	folded   map[string]bool
+	foldBtns map[string]*widget.Clickable
+	// Files whose preview shows outline numbers on headings
+	numbered     map[string]bool
+	btnNumbering widget.Clickable
+	// Decoded preview images by path, and the full-window viewer (nil =
+	// closed; see images.go)
+	images     map[string]*imageEntry
+	imageClock uint64
+	viewer     *imageViewer
+	// Rendered diagrams by diagramBlock key, the key each diagram slot is
+	// rendering, and when renders may start after an edit (see diagrams.go)
+	diagrams      map[string]*previewImage
+	diagramRuns   map[int]string
+	diagramsAfter time.Time
 
 	// Modal overlay (nil = none shown)
 	modal *modalState
@@ -61,16 +121,117 @@ type App struct {
 	btnOpen widget.Clickable
 	btnSave widget.Clickable
 
+	// Status bar encoding, line ending and flavor selectors
+	btnEncoding   widget.Clickable
+	btnLineEnding widget.Clickable
+	btnFlavor     widget.Clickable
+	// Status bar saved-time toggle (Config.ShowSavedTime)
+	btnSavedTime widget.Clickable
+
+	// Breadcrumb segment buttons, grown as needed
+	crumbBtns []widget.Clickable
+
 	// Theme buttons
 	btnLight widget.Clickable
 	btnDark  widget.Clickable
 	btnSepia widget.Clickable
-
-	// Global key shortcut tag (registered on background rect each frame)
-	keyTag struct{}
+	btnHelp  widget.Clickable
+
+	// Clipboard reads requested by the paste commands
+	pasteTag  struct{}
+	pasteMode pasteMode
+
+	// Distraction-free mode: editor only, caret line kept vertically centered
+	focusMode bool
+
+	// Set when a command moved the caret and the editor should take focus
+	// on the next frame
+	focusEditor bool
+
+	// Collapsed editor regions, oldest first (see fold.go)
+	folds   []fold
+	foldSeq int
+
+	// Document overview beside the editor (Config.ShowMinimap)
+	minimap minimap
+	// Measured x offset of the column guide (Config.ShowRuler)
+	ruler rulerCache
+	// Measured delimiter pair at the caret (Config.MatchDelimiters)
+	delims delimiterCache
+	// Vim/emacs keymap state (Config.Keymap, see vim.go and emacs.go)
+	keymap keymapState
+	// Dictionary popup for the word at the caret (nil = closed)
+	define *definition
 
 	// Channel: zenity goroutine → frame loop
-	openFolderCh chan string
+	openFolderCh chan folderChoice
+	// Channel: clipboard image reader → frame loop
+	imagePasteCh chan imagePaste
+	// Channel: clipboard HTML reader → frame loop
+	htmlPasteCh chan htmlPaste
+	// Channel: git status goroutine → frame loop
+	gitStatusCh chan gitStatusResult
+	gitCommitCh chan gitCommitResult
+	// Channel: git autosave snapshots → frame loop
+	gitAutosaveCh chan gitAutosaveResult
+	// Channel: image decoders → frame loop
+	imageCh chan imageResult
+	// Channel: diagram renderers → frame loop
+	diagramCh chan diagramResult
+	// Channel: dictionary lookup → frame loop
+	defineCh chan definition
+	// Channel: tree folder readers → frame loop
+	treeCh chan treeListing
+	// Channel: site and combined exports → frame loop
+	exportCh chan exportResult
+	// Channel: zenity note picker for Combine Notes → frame loop
+	combinePickCh chan []string
+	// Channel: zenity save dialogs (Save As, exports) → frame loop
+	savePickCh chan savePick
+
+	// Background tasks in flight; the status bar shows a spinner while > 0.
+	// Only touched on the UI goroutine: incremented when a task starts and
+	// decremented when its result is drained from its channel.
+	tasks int
+
+	// Transient messages shown in the bottom-right corner
+	toasts []toast
+
+	// Modification time of currentFile as of the last load or save
+	savedAt time.Time
+
+	// Crash-recovery snapshot: when it was last written and what it held
+	recoveryAt   time.Time
+	recoveryText string
+
+	// Markdown cheat-sheet overlay (F1)
+	showHelp   bool
+	helpBlocks []renderedBlock
+	helpList   widget.List
+
+	// Document statistics panel (Ctrl+Shift+I), and the text they describe
+	showStats bool
+	stats     docStats
+	statsText string
+
+	// Recent files card (Ctrl+Shift+E), one button per Config.RecentFiles
+	showRecent bool
+	recentBtns []widget.Clickable
+
+	// Word count of wordsFile for the word goal, and whether it has been met
+	words     int
+	wordsFile string
+	goalMet   bool
+}
+
+type rulerKey struct {
+	column  int
+	pxPerSp float32
+}
+
+type rulerCache struct {
+	key rulerKey
+	x   int
 }
 
 // ---------------------------------------------------------------------------
@@ -78,11 +239,16 @@ type App struct {
 // ---------------------------------------------------------------------------
 
 type dragHandle struct {
-	active  bool
-	lastPos float32
-	tag     struct{}
+	active    bool
+	lastPos   float32
+	lastPress time.Duration // time of the last press, to spot double-clicks
+	vertical  bool          // drags up and down, between stacked panes
+	tag       struct{}
 }
 
+// doubleClickTime is the longest gap between the presses of a double-click.
+const doubleClickTime = 400 * time.Millisecond
+
 // ---------------------------------------------------------------------------
 // Modal state
 // ---------------------------------------------------------------------------
@@ -103,6 +269,14 @@ type modalState struct {
 	btnCancel widget.Clickable
 	onOK      func(string)
 	onCancel  func()
+
+	// Optional choices shown under the input; option is the selected index.
+	options    []string
+	option     int
+	optionBtns []widget.Clickable
+
+	// okLabel overrides the OK button text when set.
+	okLabel string
 }
 
 // ---------------------------------------------------------------------------
@@ -110,11 +284,27 @@ type modalState struct {
 // ---------------------------------------------------------------------------
 
 func newApp() *App {
+	cfg, cfgErr := loadConfig()
+	saved, _ := configFields(cfg)
 	return &App{
-		treeSplit:    0.22,
-		editorSplit:  0.5,
-		status:       "Open a folder to get started  |  Ctrl+O",
-		openFolderCh: make(chan string, 1),
+		cfg:           cfg,
+		configErr:     cfgErr,
+		savedConfig:   saved,
+		stackDrag:     dragHandle{vertical: true},
+		status:        "Open a folder to get started  |  Ctrl+O",
+		openFolderCh:  make(chan folderChoice, 1),
+		imagePasteCh:  make(chan imagePaste, 1),
+		htmlPasteCh:   make(chan htmlPaste, 1),
+		gitStatusCh:   make(chan gitStatusResult, 1),
+		gitCommitCh:   make(chan gitCommitResult, 1),
+		gitAutosaveCh: make(chan gitAutosaveResult, 1),
+		imageCh:       make(chan imageResult, 1),
+		diagramCh:     make(chan diagramResult, 1),
+		treeCh:        make(chan treeListing, 1),
+		exportCh:      make(chan exportResult, 1),
+		combinePickCh: make(chan []string, 1),
+		savePickCh:    make(chan savePick, 1),
+		defineCh:      make(chan definition, 1),
 	}
 }
 
@@ -125,24 +315,99 @@ func (a *App) run() error {
 	)
 
 	a.th = material.NewTheme()
-	a.th.Shaper = text.NewShaper(text.WithCollection(gofont.Collection()))
+	a.applyTheme(themeNamed(a.cfg.Theme))
+	a.loadFonts()
+	a.md = newMarkdownParsers(a.cfg.MarkdownFlavor)
+	if a.configErr != nil {
+		a.notifyError(fmt.Errorf("config not loaded, using defaults: %w", a.configErr))
+	}
 
 	a.editor.SingleLine = false
 	a.fileTree = newFileTree(a)
 	a.previewList.Axis = layout.Vertical
+	if a.primary {
+		a.restoreSession()
+	}
 
 	ops := new(op.Ops)
 	for {
 		switch e := a.window.Event().(type) {
 		case app.DestroyEvent:
+			if a.primary {
+				a.saveSession()
+			}
+			removeRecovery(a.currentFile)
 			return e.Err
+		case app.ConfigEvent:
+			if a.focused && !e.Config.Focused {
+				a.saveOnFocusLoss()
+			}
+			a.focused = e.Config.Focused
 		case app.FrameEvent:
 			gtx := app.NewContext(ops, e)
 
 			// Drain folder path from zenity goroutine.
 			select {
-			case p := <-a.openFolderCh:
-				a.openFolder(p)
+			case f := <-a.openFolderCh:
+				a.openFolder(f.path, f.readOnly)
+			default:
+			}
+			select {
+			case img := <-a.imagePasteCh:
+				a.tasks--
+				a.insertPastedImage(img)
+			default:
+			}
+			select {
+			case p := <-a.htmlPasteCh:
+				a.tasks--
+				a.applyHTMLPaste(gtx, p)
+			default:
+			}
+			select {
+			case res := <-a.gitStatusCh:
+				a.applyGitStatus(res)
+			default:
+			}
+			select {
+			case res := <-a.gitCommitCh:
+				a.applyGitCommit(res)
+			default:
+			}
+			select {
+			case res := <-a.gitAutosaveCh:
+				a.applyGitAutosave(res)
+			default:
+			}
+			select {
+			case res := <-a.imageCh:
+				a.applyImage(res)
+			default:
+			}
+			select {
+			case res := <-a.diagramCh:
+				a.applyDiagram(res)
+			default:
+			}
+			select {
+			case d := <-a.defineCh:
+				a.applyDefinition(d)
+			default:
+			}
+			a.fileTree.drainListings()
+			select {
+			case res := <-a.exportCh:
+				a.applyExport(res)
+			default:
+			}
+			select {
+			case notes := <-a.combinePickCh:
+				a.promptCombine(a.treeOrder(notes), "")
+			default:
+			}
+			select {
+			case pick := <-a.savePickCh:
+				a.confirmOverwrite(pick.targets, pick.write)
 			default:
 			}
 
@@ -160,17 +425,37 @@ func (a *App) layout(gtx layout.Context) layout.Dimensions {
 	// Background fill.
 	paint.FillShape(gtx.Ops, a.th.Palette.Bg, clip.Rect{Max: gtx.Constraints.Max}.Op())
 
-	// Register global key shortcut area.
-	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
-	event.Op(gtx.Ops, &a.keyTag)
 	a.handleKeys(gtx)
+	a.handlePaste(gtx)
+	a.writeRecovery(gtx)
+	a.pollGitStatus(gtx)
+	a.pollGitAutosave(gtx)
+
+	var dims layout.Dimensions
+	if a.focusMode {
+		dims = a.layoutFocusMode(gtx)
+	} else {
+		dims = layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(a.layoutToolbar),
+			layout.Rigid(a.layoutBreadcrumbs),
+			layout.Flexed(1, a.layoutMain),
+			layout.Rigid(a.layoutStatusBar),
+		)
+	}
 
-	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
-		layout.Rigid(a.layoutToolbar),
-		layout.Flexed(1, a.layoutMain),
-		layout.Rigid(a.layoutStatusBar),
-	)
-
+	if a.showHelp {
+		a.layoutHelp(gtx)
+	}
+	if a.showStats {
+		a.layoutStats(gtx)
+	}
+	if a.showRecent {
+		a.layoutRecent(gtx)
+	}
+	if a.viewer != nil {
+		a.layoutImageViewer(gtx)
+	}
+	a.layoutToasts(gtx)
 	if a.modal != nil {
 		a.layoutModal(gtx)
 	}
@@ -187,19 +472,22 @@ func (a *App) layoutToolbar(gtx layout.Context) layout.Dimensions {
 		a.promptNewFile()
 	}
 	if a.btnOpen.Clicked(gtx) {
-		a.promptOpenFolder()
+		a.promptOpenFolder(false)
 	}
 	if a.btnSave.Clicked(gtx) {
 		a.saveFile()
 	}
 	if a.btnLight.Clicked(gtx) {
-		a.applyTheme(themeLight)
+		a.setTheme(themeLight)
 	}
 	if a.btnDark.Clicked(gtx) {
-		a.applyTheme(themeDark)
+		a.setTheme(themeDark)
 	}
 	if a.btnSepia.Clicked(gtx) {
-		a.applyTheme(themeSepia)
+		a.setTheme(themeSepia)
+	}
+	if a.btnHelp.Clicked(gtx) {
+		a.toggleHelp()
 	}
 
 	toolbarBg := darkenColor(a.th.Palette.Bg, 14)
@@ -236,6 +524,10 @@ func (a *App) layoutToolbar(gtx layout.Context) layout.Dimensions {
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return material.Button(a.th, &a.btnSepia, "Sepia").Layout(gtx)
 			}),
+			layout.Rigid(spacer(6)),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return material.Button(a.th, &a.btnHelp, "?").Layout(gtx)
+			}),
 		)
 	})
 }
@@ -246,6 +538,76 @@ func spacer(dp float32) layout.Widget {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Breadcrumbs
+// ---------------------------------------------------------------------------
+
+// breadcrumbPaths returns the root folder, each intermediate folder, and the
+// current file, in order. It is empty when no file inside the root is open.
+func (a *App) breadcrumbPaths() []string {
+	if a.rootPath == "" || a.currentFile == "" {
+		return nil
+	}
+	rel, err := filepath.Rel(a.rootPath, a.currentFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	paths := []string{a.rootPath}
+	p := a.rootPath
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func (a *App) layoutBreadcrumbs(gtx layout.Context) layout.Dimensions {
+	paths := a.breadcrumbPaths()
+	if len(paths) == 0 {
+		return layout.Dimensions{}
+	}
+	for len(a.crumbBtns) < len(paths) {
+		a.crumbBtns = append(a.crumbBtns, widget.Clickable{})
+	}
+	for i, p := range paths {
+		if a.crumbBtns[i].Clicked(gtx) {
+			if i < len(paths)-1 {
+				a.selectedPath = p
+			}
+			a.fileTree.Reveal(p)
+		}
+	}
+
+	var children []layout.FlexChild
+	for i, p := range paths {
+		i, p := i, p
+		if i > 0 {
+			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Label(a.th, unit.Sp(12), " › ")
+				lbl.Color = mulAlpha(a.th.Palette.Fg, 140)
+				return lbl.Layout(gtx)
+			}))
+		}
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return material.Clickable(gtx, &a.crumbBtns[i], func(gtx layout.Context) layout.Dimensions {
+				return layout.UniformInset(unit.Dp(2)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Label(a.th, unit.Sp(12), filepath.Base(p))
+					if i == len(paths)-1 {
+						lbl.Font.Weight = font.SemiBold
+					}
+					return lbl.Layout(gtx)
+				})
+			})
+		}))
+	}
+
+	return layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(2), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, children...)
+		},
+	)
+}
+
 // ---------------------------------------------------------------------------
 // Main split area
 // ---------------------------------------------------------------------------
@@ -255,16 +617,44 @@ func (a *App) layoutMain(gtx layout.Context) layout.Dimensions {
 	a.mainWidth = total
 	handleW := gtx.Dp(5)
 
-	restForEditorSplit := total - int(float32(total)*a.treeSplit) - handleW*2
-	a.processDrag(gtx, &a.treeDrag, &a.treeSplit, total)
-	a.processDrag(gtx, &a.editorDrag, &a.editorSplit, restForEditorSplit)
+	restForEditorSplit := total - int(float32(total)*a.cfg.TreeSplit) - handleW*2
+	if a.processDrag(gtx, &a.treeDrag, &a.cfg.TreeSplit, defaultTreeSplit, total) {
+		a.saveConfig()
+	}
+
+	// Narrow windows stack the panes (and may drop the tree) until widened
+	// again; the saved layout is left alone.
+	narrow := a.cfg.NarrowWidth > 0 && total < gtx.Dp(unit.Dp(a.cfg.NarrowWidth))
+	if a.cfg.StackedLayout || narrow {
+		return a.layoutStacked(gtx, handleW, !(narrow && a.cfg.NarrowHidesTree))
+	}
 
-	treeW := int(float32(total) * a.treeSplit)
+	// editorSplit is always the editor's share; with the panes swapped the
+	// handle moves the preview's edge, so drag its complement.
+	leftSplit, leftDefault := a.cfg.EditorSplit, float32(defaultEditorSplit)
+	if a.cfg.PreviewOnLeft {
+		leftSplit, leftDefault = 1-a.cfg.EditorSplit, 1-leftDefault
+	}
+	settled := a.processDrag(gtx, &a.editorDrag, &leftSplit, leftDefault, restForEditorSplit)
+	if a.cfg.PreviewOnLeft {
+		a.cfg.EditorSplit = 1 - leftSplit
+	} else {
+		a.cfg.EditorSplit = leftSplit
+	}
+	if settled {
+		a.saveConfig()
+	}
+
+	treeW := int(float32(total) * a.cfg.TreeSplit)
 	rest := total - treeW - handleW*2
 	if rest < 80 {
 		rest = 80
 	}
-	editorW := int(float32(rest) * a.editorSplit)
+	leftW := int(float32(rest) * leftSplit)
+	left, right := a.layoutEditor, a.layoutPreview
+	if a.cfg.PreviewOnLeft {
+		left, right = right, left
+	}
 
 	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -275,17 +665,80 @@ func (a *App) layoutMain(gtx layout.Context) layout.Dimensions {
 			return a.layoutSplitBar(gtx, &a.treeDrag, handleW)
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			gtx.Constraints = layout.Exact(image.Pt(editorW, gtx.Constraints.Max.Y))
-			return a.layoutEditor(gtx)
+			gtx.Constraints = layout.Exact(image.Pt(leftW, gtx.Constraints.Max.Y))
+			return left(gtx)
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			return a.layoutSplitBar(gtx, &a.editorDrag, handleW)
 		}),
-		layout.Flexed(1, a.layoutPreview),
+		layout.Flexed(1, right),
 	)
 }
 
-func (a *App) processDrag(gtx layout.Context, h *dragHandle, ratio *float32, totalPx int) {
+// layoutStacked draws the editor and preview stacked one above the other,
+// each split with its own handle, beside the tree when showTree is set.
+func (a *App) layoutStacked(gtx layout.Context, handleW int, showTree bool) layout.Dimensions {
+	total := gtx.Constraints.Max.X
+	height := gtx.Constraints.Max.Y
+	if a.processDrag(gtx, &a.stackDrag, &a.cfg.StackSplit, defaultStackSplit, height-handleW) {
+		a.saveConfig()
+	}
+
+	treeW := int(float32(total) * a.cfg.TreeSplit)
+	topH := int(float32(height-handleW) * a.cfg.StackSplit)
+	top, bottom := a.layoutEditor, a.layoutPreview
+	if a.cfg.PreviewOnLeft {
+		top, bottom = bottom, top
+	}
+	panes := func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints = layout.Exact(image.Pt(gtx.Constraints.Max.X, topH))
+				return top(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return a.layoutSplitBar(gtx, &a.stackDrag, handleW)
+			}),
+			layout.Flexed(1, bottom),
+		)
+	}
+	if !showTree {
+		return panes(gtx)
+	}
+
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints = layout.Exact(image.Pt(treeW, gtx.Constraints.Max.Y))
+			return a.fileTree.Layout(gtx, a.th)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return a.layoutSplitBar(gtx, &a.treeDrag, handleW)
+		}),
+		layout.Flexed(1, panes),
+	)
+}
+
+// toggleStacked switches between side-by-side and stacked editor and preview,
+// and remembers the choice.
+func (a *App) toggleStacked() {
+	a.cfg.StackedLayout = !a.cfg.StackedLayout
+	a.saveConfig()
+	a.window.Invalidate()
+}
+
+// swapPanes puts the preview on the other side of the editor and remembers
+// the choice.
+func (a *App) swapPanes() {
+	a.cfg.PreviewOnLeft = !a.cfg.PreviewOnLeft
+	a.saveConfig()
+	a.window.Invalidate()
+}
+
+// processDrag moves *ratio with drags on h, and resets it to def on a
+// double-click. It reports whether a drag ended or the ratio was reset, so
+// the caller can save the new split.
+func (a *App) processDrag(gtx layout.Context, h *dragHandle, ratio *float32, def float32, totalPx int) bool {
+	settled := false
 	for {
 		e, ok := gtx.Event(pointer.Filter{
 			Target: &h.tag,
@@ -298,31 +751,45 @@ func (a *App) processDrag(gtx layout.Context, h *dragHandle, ratio *float32, tot
 		if !ok {
 			continue
 		}
+		pos := pe.Position.X
+		if h.vertical {
+			pos = pe.Position.Y
+		}
 		switch pe.Kind {
 		case pointer.Press:
+			if h.lastPress != 0 && pe.Time-h.lastPress < doubleClickTime {
+				*ratio = def
+				h.active = false
+				h.lastPress = 0
+				settled = true
+				a.window.Invalidate()
+				continue
+			}
 			h.active = true
-			h.lastPos = pe.Position.X
+			h.lastPos = pos
+			h.lastPress = pe.Time
 		case pointer.Drag:
 			if h.active && totalPx > 0 {
-				delta := pe.Position.X - h.lastPos
-				h.lastPos = pe.Position.X
+				delta := pos - h.lastPos
+				h.lastPos = pos
 				*ratio += delta / float32(totalPx)
-				if *ratio < 0.1 {
-					*ratio = 0.1
-				}
-				if *ratio > 0.85 {
-					*ratio = 0.85
-				}
 				a.window.Invalidate()
 			}
 		case pointer.Release:
+			settled = settled || h.active
 			h.active = false
 		}
 	}
+	// Also keeps hand-edited config values in range.
+	*ratio = min(max(*ratio, 0.1), 0.85)
+	return settled
 }
 
 func (a *App) layoutSplitBar(gtx layout.Context, h *dragHandle, w int) layout.Dimensions {
 	size := image.Pt(w, gtx.Constraints.Max.Y)
+	if h.vertical {
+		size = image.Pt(gtx.Constraints.Max.X, w)
+	}
 
 	barColor := mulAlpha(a.th.Palette.Fg, 40)
 	if h.active {
@@ -341,29 +808,235 @@ func (a *App) layoutSplitBar(gtx layout.Context, h *dragHandle, w int) layout.Di
 // ---------------------------------------------------------------------------
 
 func (a *App) layoutEditor(gtx layout.Context) layout.Dimensions {
+	a.handleVimKeys(gtx)
+	a.handleEmacsKeys(gtx)
+
+	// Intercept Tab before the editor sees it (it would otherwise move focus).
+	// A read-only editor (vim's normal mode) keeps focus but is not indented.
+	for {
+		e, ok := gtx.Event(key.Filter{Focus: &a.editor, Name: key.NameTab, Optional: key.ModShift})
+		if !ok {
+			break
+		}
+		if ke, ok := e.(key.Event); ok && ke.State == key.Press && !a.editor.ReadOnly {
+			a.indentSelection(ke.Modifiers.Contain(key.ModShift))
+		}
+	}
+
+	// Enter keeps the line's indentation, unless the editor is read-only
+	// (which includes vim's normal mode).
+	if a.cfg.AutoIndent && !a.editor.ReadOnly {
+		for {
+			e, ok := gtx.Event(
+				key.Filter{Focus: &a.editor, Name: key.NameReturn, Optional: key.ModShift},
+				key.Filter{Focus: &a.editor, Name: key.NameEnter, Optional: key.ModShift},
+			)
+			if !ok {
+				break
+			}
+			if ke, ok := e.(key.Event); ok && ke.State == key.Press {
+				a.newlineWithIndent()
+			}
+		}
+	}
+
+	// Ctrl+Home/End jump to the start or end of the document.
+	for {
+		e, ok := gtx.Event(
+			key.Filter{Focus: &a.editor, Name: key.NameHome, Required: key.ModCtrl},
+			key.Filter{Focus: &a.editor, Name: key.NameEnd, Required: key.ModCtrl},
+		)
+		if !ok {
+			break
+		}
+		if ke, ok := e.(key.Event); ok && ke.State == key.Press {
+			pos := 0
+			if ke.Name == key.NameEnd {
+				pos = a.editor.Len()
+			}
+			if a.vimEnabled() && a.keymap.mode == vimNormal {
+				a.vimSetCursor([]rune(a.editor.Text()), pos)
+			} else {
+				a.editor.SetCaret(pos, pos)
+			}
+		}
+	}
+
+	// Alt+Up/Down move the caret's line or the selected lines; Ctrl+D
+	// duplicates them and Ctrl+Shift+J joins them. Alt+Shift+I puts a caret
+	// on each selected line. Alt+Q rewraps the caret's paragraph and
+	// Alt+Shift+Q unwraps it.
+	if !a.editor.ReadOnly {
+		for {
+			e, ok := gtx.Event(
+				key.Filter{Focus: &a.editor, Name: key.NameUpArrow, Required: key.ModAlt},
+				key.Filter{Focus: &a.editor, Name: key.NameDownArrow, Required: key.ModAlt},
+				key.Filter{Focus: &a.editor, Name: "D", Required: key.ModCtrl},
+				key.Filter{Focus: &a.editor, Name: "I", Required: key.ModAlt | key.ModShift},
+				key.Filter{Focus: &a.editor, Name: "J", Required: key.ModCtrl | key.ModShift},
+				key.Filter{Focus: &a.editor, Name: "Q", Required: key.ModAlt, Optional: key.ModShift},
+			)
+			if !ok {
+				break
+			}
+			ke, ok := e.(key.Event)
+			if !ok || ke.State != key.Press {
+				continue
+			}
+			switch ke.Name {
+			case key.NameUpArrow:
+				a.moveLines(-1)
+			case key.NameDownArrow:
+				a.moveLines(1)
+			case "D":
+				a.duplicateLines()
+			case "I":
+				a.caretsOnLines()
+			case "J":
+				a.joinLines()
+			case "Q":
+				a.reflowParagraph(ke.Modifiers.Contain(key.ModShift))
+			}
+		}
+	}
+
+	if a.focusEditor {
+		a.focusEditor = false
+		gtx.Execute(key.FocusCmd{Tag: &a.editor})
+	}
+
 	// Poll editor for text changes.
+	a.handleCaretClicks(gtx)
 	for {
 		ev, ok := a.editor.Update(gtx)
 		if !ok {
 			break
 		}
 		if _, ok := ev.(widget.ChangeEvent); ok {
-			if !a.loading {
-				a.modified = true
+			// Change events also follow programmatic SetText calls (a frame
+			// later), so compare against the saved text rather than assuming
+			// every change is an edit.
+			if a.hasExtraCarets() {
+				a.repeatEdit()
+			}
+			content := a.docText()
+			if modified := content != a.savedText; modified != a.modified {
+				a.modified = modified
 				a.updateTitle()
-				a.previewBlocks = renderMarkdown(a.editor.Text())
 			}
+			if a.livePreview() {
+				a.previewBlocks = renderMarkdown(content, a.renderOptions())
+				a.diagramsAfter = gtx.Now.Add(diagramSettle)
+			}
+			a.minimap.setText(content)
+			a.countWords(content)
 		}
 	}
+	a.syncCarets()
+	a.unfoldAtCaret()
 
 	paint.FillShape(gtx.Ops, a.th.Palette.Bg, clip.Rect{Max: gtx.Constraints.Max}.Op())
-	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		ed := material.Editor(a.th, &a.editor, "Select a file to start editing…")
-		ed.TextSize = unit.Sp(14)
-		return ed.Layout(gtx)
+	editor := func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			ed := material.Editor(a.th, &a.editor, "Select a file to start editing…")
+			ed.TextSize = editorTextSize
+			ed.Font.Typeface = a.editorTypeface()
+			ed.LineHeightScale = a.editorLineScale()
+			if a.cfg.ShowRuler && a.cfg.RulerColumn > 0 {
+				a.drawRuler(gtx, ed)
+			}
+			if a.cfg.MatchDelimiters && !a.focusMode {
+				a.drawDelimiterMatch(gtx, ed)
+			}
+			if a.focusMode {
+				return layoutTypewriter(gtx, &a.editor, ed.Layout)
+			}
+			dims := ed.Layout(gtx)
+			a.layoutCarets(gtx, dims.Size)
+			if a.define != nil {
+				a.layoutDefinition(gtx)
+			}
+			return dims
+		})
+	}
+	if !a.cfg.ShowMinimap || a.focusMode {
+		return editor(gtx)
+	}
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Flexed(1, editor),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return a.layoutMinimap(gtx, gtx.Constraints.Max.Y-gtx.Dp(8))
+		}),
+	)
+}
+
+// drawRuler paints a faint vertical guide at the configured column. Its
+// position is the width of that many "x" characters in the editor font; the
+// font is proportional, so this matches average prose rather than any line.
+func (a *App) drawRuler(gtx layout.Context, ed material.EditorStyle) {
+	key := rulerKey{column: a.cfg.RulerColumn, pxPerSp: gtx.Metric.PxPerSp}
+	if a.ruler.key != key {
+		rec := op.Record(gtx.Ops)
+		lgtx := gtx
+		lgtx.Constraints = layout.Constraints{Max: image.Pt(1<<20, 1<<20)}
+		lbl := material.Label(a.th, ed.TextSize, strings.Repeat("x", key.column))
+		lbl.MaxLines = 1
+		lbl.Font = ed.Font
+		dims := lbl.Layout(lgtx)
+		rec.Stop()
+		a.ruler = rulerCache{key: key, x: dims.Size.X}
+	}
+	if a.ruler.x >= gtx.Constraints.Max.X {
+		return
+	}
+	rect := image.Rect(a.ruler.x, 0, a.ruler.x+max(gtx.Dp(1), 1), gtx.Constraints.Max.Y)
+	paint.FillShape(gtx.Ops, mulAlpha(a.th.Palette.Fg, 30), clip.Rect(rect).Op())
+}
+
+// layoutTypewriter lays out the editor at its full text height and shifts it
+// so the caret line sits at the vertical centre of the viewport. The editor
+// never scrolls internally; the offset is recomputed every frame after layout
+// so the caret position is current.
+func layoutTypewriter(gtx layout.Context, ed *widget.Editor, w layout.Widget) layout.Dimensions {
+	viewport := gtx.Constraints.Max
+	defer clip.Rect{Max: viewport}.Push(gtx.Ops).Pop()
+
+	inner := gtx
+	inner.Constraints = layout.Constraints{
+		Min: image.Pt(viewport.X, 0),
+		Max: image.Pt(viewport.X, 1<<20),
+	}
+	rec := op.Record(gtx.Ops)
+	w(inner)
+	call := rec.Stop()
+
+	offsetY := viewport.Y/2 - int(ed.CaretCoords().Y)
+	defer op.Offset(image.Pt(0, offsetY)).Push(gtx.Ops).Pop()
+	call.Add(gtx.Ops)
+	return layout.Dimensions{Size: viewport}
+}
+
+// layoutFocusMode shows only the editor in a centered column.
+func (a *App) layoutFocusMode(gtx layout.Context) layout.Dimensions {
+	maxW := gtx.Dp(760)
+	return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		w := gtx.Constraints.Max.X
+		if w > maxW {
+			w = maxW
+		}
+		gtx.Constraints = layout.Exact(image.Pt(w, gtx.Constraints.Max.Y))
+		return a.layoutEditor(gtx)
 	})
 }
 
+// toggleFocusMode enters or leaves distraction-free mode, keeping the
+// editor focused.
+func (a *App) toggleFocusMode(gtx layout.Context) {
+	a.focusMode = !a.focusMode
+	gtx.Execute(key.FocusCmd{Tag: &a.editor})
+	a.window.Invalidate()
+}
+
 // ---------------------------------------------------------------------------
 // Preview panel
 // ---------------------------------------------------------------------------
@@ -372,15 +1045,269 @@ func (a *App) layoutPreview(gtx layout.Context) layout.Dimensions {
 	paint.FillShape(gtx.Ops, previewBg(a.th.Palette.Bg), clip.Rect{Max: gtx.Constraints.Max}.Op())
 
 	blocks := a.previewBlocks
-	return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		return material.List(a.th, &a.previewList).Layout(gtx, len(blocks),
-			func(gtx layout.Context, i int) layout.Dimensions {
-				return layout.Inset{Bottom: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-					return blocks[i].Layout(gtx, a.th)
-				})
-			},
-		)
+	keys := headingKeys(blocks)
+	for k, btn := range a.foldBtns {
+		if btn.Clicked(gtx) {
+			if a.folded == nil {
+				a.folded = make(map[string]bool)
+			}
+			a.folded[k] = !a.folded[k]
+		}
+	}
+	vis := visibleBlocks(blocks, keys, a.folded)
+	var diagrams []string // keys of the preview's diagrams, in order
+	for _, b := range blocks {
+		var ib *imageBlock
+		switch b := b.(type) {
+		case *imageBlock:
+			ib = b
+			if ib.img == nil {
+				ib.img = a.loadImage(ib.dest)
+			}
+		case *diagramBlock:
+			ib = &b.image
+			if ib.img == nil {
+				ib.img = a.diagramImage(gtx, b, len(diagrams))
+			}
+			diagrams = append(diagrams, b.key)
+		default:
+			continue
+		}
+		if ib.click.Clicked(gtx) && ib.img != nil && ib.img.err == nil {
+			a.openImageViewer(gtx, ib)
+		}
+	}
+	maps.DeleteFunc(a.diagrams, func(k string, _ *previewImage) bool {
+		return !slices.Contains(diagrams, k)
 	})
+	if a.btnNumbering.Clicked(gtx) {
+		if a.numbered == nil {
+			a.numbered = make(map[string]bool)
+		}
+		a.numbered[a.currentFile] = !a.numbered[a.currentFile]
+	}
+	numbered := a.numbered[a.currentFile]
+	a.handlePreviewKeys(gtx)
+
+	st := a.previewStyle()
+	spacing := unit.Dp(a.cfg.PreviewBlockSpacing)
+	maxW := gtx.Dp(unit.Dp(a.cfg.PreviewMaxWidth))
+	list := func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return fadingList(gtx, a.th, &a.previewList, &a.previewFade).Layout(gtx, len(vis),
+				func(gtx layout.Context, i int) layout.Dimensions {
+					i = vis[i]
+					return layoutColumn(gtx, maxW, func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Bottom: spacing}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							h, ok := blocks[i].(*headingBlock)
+							if !ok {
+								return blocks[i].Layout(gtx, a.th, st)
+							}
+							h.foldBtn, h.folded, h.numbered = a.foldButton(keys[i]), a.folded[keys[i]], numbered
+							return h.Layout(gtx, a.th, st)
+						})
+					})
+				},
+			)
+		})
+	}
+	// Register the pane for keyboard focus beneath the list's own handlers.
+	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, &a.previewTag)
+
+	if a.currentFile == "" {
+		return list(gtx)
+	}
+	return layout.Stack{Alignment: layout.NE}.Layout(gtx,
+		layout.Expanded(list),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			// Outline-numbering toggle, dimmed while off.
+			return layout.Inset{Top: unit.Dp(6), Right: unit.Dp(18)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				btn := treeHeaderButton(a.th, &a.btnNumbering, "1.2")
+				if !numbered {
+					btn.Background = mulAlpha(a.th.Palette.ContrastBg, 90)
+				}
+				return btn.Layout(gtx)
+			})
+		}),
+	)
+}
+
+// renderOptions returns the settings for rendering markdown into the
+// preview.
+func (a *App) renderOptions() renderOptions {
+	return renderOptions{
+		md:        a.md.parser(a.cfg.SmartQuotes),
+		html:      strings.ToLower(a.cfg.PreviewHTML),
+		joinLines: a.cfg.JoinSoftBreaks,
+	}
+}
+
+// editorTextSize is the size of the editor's text.
+const editorTextSize = unit.Sp(14)
+
+// editorLineScale is the editor's line height as a multiple of its text
+// size: EditorLineHeight clamped to 1–2.5, or Gio's default of 1.2 if unset.
+func (a *App) editorLineScale() float32 {
+	if h := a.cfg.EditorLineHeight; h > 0 {
+		return min(max(h, 1), 2.5)
+	}
+	return 1.2
+}
+
+// previewStyle returns the typography settings for drawing preview blocks.
+func (a *App) previewStyle() *previewStyle {
+	return &previewStyle{
+		LineHeight: a.cfg.PreviewLineHeight,
+		Code:       codeSchemes[a.cfg.CodeTheme],
+		OnLink:     a.followLink,
+		Mono:       a.monoTypeface(),
+
+		ImageMaxWidth: unit.Dp(a.cfg.PreviewImageMaxWidth),
+	}
+}
+
+// Values of Config.WebLinkClick and NoteLinkClick.
+const (
+	linkClickPlain    = "click"
+	linkClickModified = "ctrl+click"
+)
+
+// followLink handles a pressed preview link: "#slug" scrolls to that
+// heading, anything with a scheme opens in the system browser, and a
+// relative link to a note opens it, revealed in the tree. The last two may
+// need Ctrl held, as the link click settings say.
+func (a *App) followLink(dest string, mods key.Modifiers) {
+	if slug, ok := strings.CutPrefix(dest, "#"); ok {
+		a.scrollToSlug(slug)
+		return
+	}
+	u, err := url.Parse(dest)
+	if err == nil && u.Scheme != "" {
+		if !a.linkClickFollows(a.cfg.WebLinkClick, mods, dest) {
+			return
+		}
+		if err := openWithSystem(dest); err != nil {
+			a.notifyError(err)
+		}
+		return
+	}
+	if err == nil && u.Path != "" && a.currentFile != "" {
+		p := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(a.currentFile), p)
+		}
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && a.isNoteFile(p) {
+			if !a.linkClickFollows(a.cfg.NoteLinkClick, mods, dest) {
+				return
+			}
+			a.revealInTree(p)
+			if p != a.currentFile {
+				a.confirmSwitch(p)
+			}
+			if u.Fragment != "" && p == a.currentFile {
+				a.scrollToSlug(u.Fragment)
+			}
+			return
+		}
+	}
+	a.status = "Link: " + dest
+}
+
+// linkClickFollows reports whether a press with mods follows a link under
+// the given click setting; if not, it says how to follow it.
+func (a *App) linkClickFollows(setting string, mods key.Modifiers, dest string) bool {
+	if !strings.EqualFold(setting, linkClickModified) || mods.Contain(key.ModShortcut) {
+		return true
+	}
+	mod := "Ctrl"
+	if runtime.GOOS == "darwin" {
+		mod = "Cmd"
+	}
+	a.status = mod + "+Click to open " + dest
+	return false
+}
+
+// scrollToSlug scrolls the preview to the heading with the given slug,
+// unfolding the preview if the heading is folded away.
+func (a *App) scrollToSlug(slug string) {
+	blocks := a.previewBlocks
+	target := -1
+	for i, b := range blocks {
+		if h, ok := b.(*headingBlock); ok && h.slug == slug {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		a.status = "No heading #" + slug
+		return
+	}
+	vis := visibleBlocks(blocks, headingKeys(blocks), a.folded)
+	if !slices.Contains(vis, target) {
+		a.folded = nil
+		vis = visibleBlocks(blocks, headingKeys(blocks), nil)
+	}
+	a.previewList.Position = layout.Position{First: slices.Index(vis, target)}
+	a.window.Invalidate()
+}
+
+// handlePreviewKeys gives the preview keyboard focus when clicked and scrolls
+// it to the top or bottom on Ctrl+Home/End.
+func (a *App) handlePreviewKeys(gtx layout.Context) {
+	for {
+		e, ok := gtx.Event(
+			pointer.Filter{Target: &a.previewTag, Kinds: pointer.Press},
+			key.FocusFilter{Target: &a.previewTag},
+			key.Filter{Focus: &a.previewTag, Name: key.NameHome, Required: key.ModCtrl},
+			key.Filter{Focus: &a.previewTag, Name: key.NameEnd, Required: key.ModCtrl},
+		)
+		if !ok {
+			break
+		}
+		switch e := e.(type) {
+		case pointer.Event:
+			gtx.Execute(key.FocusCmd{Tag: &a.previewTag})
+		case key.Event:
+			if e.State != key.Press {
+				continue
+			}
+			if e.Name == key.NameHome {
+				a.previewList.Position = layout.Position{}
+			} else {
+				// The list clamps First and fills backwards from the end.
+				a.previewList.Position = layout.Position{First: len(a.previewBlocks)}
+			}
+		}
+	}
+}
+
+// foldButton returns the clickable for the heading with fold key k.
+func (a *App) foldButton(k string) *widget.Clickable {
+	if a.foldBtns == nil {
+		a.foldBtns = make(map[string]*widget.Clickable)
+	}
+	btn, ok := a.foldBtns[k]
+	if !ok {
+		btn = new(widget.Clickable)
+		a.foldBtns[k] = btn
+	}
+	return btn
+}
+
+// layoutColumn centres w in a column at most maxW pixels wide, still
+// reporting the full available width. maxW <= 0 means no limit.
+func layoutColumn(gtx layout.Context, maxW int, w layout.Widget) layout.Dimensions {
+	full := gtx.Constraints.Max.X
+	if maxW <= 0 || full <= maxW {
+		return w(gtx)
+	}
+	gtx.Constraints.Max.X = maxW
+	gtx.Constraints.Min.X = min(gtx.Constraints.Min.X, maxW)
+	defer op.Offset(image.Pt((full-maxW)/2, 0)).Push(gtx.Ops).Pop()
+	dims := w(gtx)
+	dims.Size.X = full
+	return dims
 }
 
 func previewBg(bg color.NRGBA) color.NRGBA {
@@ -402,13 +1329,177 @@ func (a *App) layoutStatusBar(gtx layout.Context) layout.Dimensions {
 	paint.FillShape(gtx.Ops, statusBg,
 		clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, gtx.Dp(24))}.Op())
 
-	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8)}.Layout(gtx,
+	if a.btnEncoding.Clicked(gtx) {
+		a.promptReopenWithEncoding((a.format.encoding + 1) % numTextEncodings)
+	}
+	if a.btnLineEnding.Clicked(gtx) {
+		a.promptLineEndings()
+	}
+	if a.btnFlavor.Clicked(gtx) {
+		a.promptMarkdownFlavor()
+	}
+	if a.btnSavedTime.Clicked(gtx) {
+		a.toggleSavedTime()
+	}
+
+	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx,
 		func(gtx layout.Context) layout.Dimensions {
-			return material.Label(a.th, unit.Sp(12), a.status).Layout(gtx)
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Label(a.th, unit.Sp(12), a.status)
+					lbl.MaxLines = 1
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if a.tasks == 0 {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Right: unit.Dp(16)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints = layout.Exact(image.Pt(gtx.Dp(14), gtx.Dp(14)))
+						return material.Loader(a.th).Layout(gtx)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if a.currentFile == "" || !a.vimEnabled() {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Right: unit.Dp(16)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return material.Label(a.th, unit.Sp(12), a.keymap.mode.label()).Layout(gtx)
+					})
+				}),
+				layout.Rigid(a.layoutWordGoal),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if a.currentFile == "" || a.savedAt.IsZero() {
+						return layout.Dimensions{}
+					}
+					// Hidden, the time leaves a dimmed label to click it back on.
+					lbl := material.Label(a.th, unit.Sp(12), "saved time")
+					if a.cfg.ShowSavedTime {
+						lbl.Text = a.savedStatus(gtx)
+					} else {
+						lbl.Color = mulAlpha(lbl.Color, 120)
+					}
+					return layout.Inset{Right: unit.Dp(16)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return material.Clickable(gtx, &a.btnSavedTime, lbl.Layout)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if a.currentFile == "" {
+						return layout.Dimensions{}
+					}
+					return material.Label(a.th, unit.Sp(12), a.caretStatus()).Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return a.layoutStatusButton(gtx, &a.btnFlavor, flavorLabels[flavorIndex(a.cfg.MarkdownFlavor)])
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return a.layoutStatusButton(gtx, &a.btnEncoding, a.format.encoding.label())
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return a.layoutStatusButton(gtx, &a.btnLineEnding, a.format.lineEnding())
+				}),
+			)
 		},
 	)
 }
 
+// layoutStatusButton draws a clickable status bar label for the open note,
+// spaced from the one before it.
+func (a *App) layoutStatusButton(gtx layout.Context, btn *widget.Clickable, label string) layout.Dimensions {
+	if a.currentFile == "" {
+		return layout.Dimensions{}
+	}
+	return layout.Inset{Left: unit.Dp(16)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return material.Clickable(gtx, btn, material.Label(a.th, unit.Sp(12), label).Layout)
+	})
+}
+
+// savedStatus describes how long ago the current file was written, and
+// schedules a redraw for when the text next changes.
+func (a *App) savedStatus(gtx layout.Context) string {
+	age := gtx.Now.Sub(a.savedAt)
+	switch {
+	case age < time.Minute:
+		gtx.Execute(op.InvalidateCmd{At: a.savedAt.Add(time.Minute)})
+		return "saved just now"
+	case age < time.Hour:
+		gtx.Execute(op.InvalidateCmd{At: gtx.Now.Add(time.Minute - age%time.Minute)})
+		return fmt.Sprintf("saved %dm ago", int(age/time.Minute))
+	case age < 24*time.Hour:
+		gtx.Execute(op.InvalidateCmd{At: gtx.Now.Add(time.Hour - age%time.Hour)})
+		return fmt.Sprintf("saved %dh ago", int(age/time.Hour))
+	}
+	return "saved " + a.savedAt.Format("Jan 2 15:04")
+}
+
+// toggleSavedTime shows or hides the saved time in the status bar, and
+// remembers the choice.
+func (a *App) toggleSavedTime() {
+	a.cfg.ShowSavedTime = !a.cfg.ShowSavedTime
+	a.saveConfig()
+	a.window.Invalidate()
+}
+
+// caretStatus describes the caret position and, if any, the selection size.
+func (a *App) caretStatus() string {
+	line, col := a.editor.CaretPos()
+	pos := fmt.Sprintf("Ln %d, Col %d", line+1, col+1)
+	if n := a.editor.SelectionLen(); n > 0 {
+		words := len(strings.Fields(a.editor.SelectedText()))
+		return fmt.Sprintf("%d chars, %d words selected  |  %s", n, words, pos)
+	}
+	return pos
+}
+
+// promptReopenWithEncoding reloads the current file as enc, confirming first
+// if that would discard unsaved changes.
+func (a *App) promptReopenWithEncoding(enc textEncoding) {
+	if !a.modified {
+		a.reopenWithEncoding(enc)
+		return
+	}
+	a.showConfirmModal(
+		"Unsaved Changes",
+		"Discard changes to '"+filepath.Base(a.currentFile)+"' and reopen it as "+enc.label()+"?",
+		func() { a.reopenWithEncoding(enc) },
+		nil,
+	)
+}
+
+// promptLineEndings asks which line endings to save the note with, then
+// saves it with them.
+func (a *App) promptLineEndings() {
+	if a.denyReadOnly() {
+		return
+	}
+	var m *modalState
+	m = a.showConfirmModal("Line Endings", "Save '"+filepath.Base(a.currentFile)+"' with line endings:", func() {
+		a.format.crlf = m.option == 1
+		a.saveFile()
+	}, nil)
+	m.okLabel = "Save"
+	m.options = []string{"LF", "CRLF"}
+	if a.format.crlf {
+		m.option = 1
+	}
+}
+
+// promptMarkdownFlavor asks which markdown flavor to use, then saves it to
+// the config and re-renders the preview with it.
+func (a *App) promptMarkdownFlavor() {
+	var m *modalState
+	m = a.showConfirmModal("Markdown Flavor", "Syntax the preview and exports understand:", func() {
+		a.cfg.MarkdownFlavor = markdownFlavors[m.option]
+		a.saveConfig()
+		a.md = newMarkdownParsers(a.cfg.MarkdownFlavor)
+		a.previewBlocks = renderMarkdown(a.docText(), a.renderOptions())
+		a.status = "Markdown flavor: " + flavorLabels[m.option]
+	}, nil)
+	m.okLabel = "Use"
+	m.options = flavorLabels
+	m.option = flavorIndex(a.cfg.MarkdownFlavor)
+}
+
 // ---------------------------------------------------------------------------
 // Modal overlay
 // ---------------------------------------------------------------------------
@@ -454,7 +1545,7 @@ func (a *App) layoutModalCard(gtx layout.Context) layout.Dimensions {
 		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				lbl := material.Label(a.th, unit.Sp(16), m.title)
-				lbl.Font = font.Font{Weight: font.Bold}
+				lbl.Font.Weight = font.Bold
 				return lbl.Layout(gtx)
 			}),
 			layout.Rigid(spacer(8)),
@@ -473,6 +1564,9 @@ func (a *App) layoutModalCard(gtx layout.Context) layout.Dimensions {
 					return ed.Layout(gtx)
 				})
 			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return a.layoutModalOptions(gtx, m)
+			}),
 			layout.Rigid(spacer(20)),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
@@ -485,7 +1579,9 @@ func (a *App) layoutModalCard(gtx layout.Context) layout.Dimensions {
 					layout.Rigid(spacer(8)),
 					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 						label := "OK"
-						if m.kind == modalConfirm {
+						if m.okLabel != "" {
+							label = m.okLabel
+						} else if m.kind == modalConfirm {
 							label = "Discard"
 						}
 						return material.Button(a.th, &m.btnOK, label).Layout(gtx)
@@ -496,17 +1592,94 @@ func (a *App) layoutModalCard(gtx layout.Context) layout.Dimensions {
 	})
 }
 
+// layoutModalOptions draws the modal's choices as a row of toggle buttons.
+func (a *App) layoutModalOptions(gtx layout.Context, m *modalState) layout.Dimensions {
+	if len(m.options) == 0 {
+		return layout.Dimensions{}
+	}
+	for len(m.optionBtns) < len(m.options) {
+		m.optionBtns = append(m.optionBtns, widget.Clickable{})
+	}
+	for i := range m.options {
+		if m.optionBtns[i].Clicked(gtx) {
+			m.option = i
+		}
+	}
+
+	var children []layout.FlexChild
+	for i, opt := range m.options {
+		i, opt := i, opt
+		if i > 0 {
+			children = append(children, layout.Rigid(spacer(4)))
+		}
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			btn := treeHeaderButton(a.th, &m.optionBtns[i], opt)
+			if i != m.option {
+				btn.Background = mulAlpha(a.th.Palette.ContrastBg, 90)
+			}
+			return btn.Layout(gtx)
+		}))
+	}
+	return layout.Inset{Top: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Keyboard shortcuts
 // ---------------------------------------------------------------------------
 
 func (a *App) handleKeys(gtx layout.Context) {
+	// Filters carry no Focus tag so shortcuts fire whichever widget (usually
+	// the editor) holds keyboard focus.
+	filters := []event.Filter{
+		key.Filter{Name: "S", Required: key.ModCtrl, Optional: key.ModShift},
+		key.Filter{Name: "S", Required: key.ModCtrl | key.ModAlt},
+		key.Filter{Name: "O", Required: key.ModCtrl, Optional: key.ModShift},
+		key.Filter{Name: "N", Required: key.ModCtrl, Optional: key.ModShift},
+		key.Filter{Name: "P", Required: key.ModCtrl},
+		key.Filter{Name: "J", Required: key.ModCtrl},
+		key.Filter{Name: "G", Required: key.ModCtrl},
+		key.Filter{Name: "G", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "[", Required: key.ModCtrl},
+		key.Filter{Name: "]", Required: key.ModCtrl},
+		key.Filter{Name: "C", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "C", Required: key.ModCtrl | key.ModAlt},
+		key.Filter{Name: "U", Required: key.ModCtrl | key.ModAlt},
+		key.Filter{Name: "L", Required: key.ModCtrl | key.ModAlt},
+		key.Filter{Name: "L", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "V", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "R", Required: key.ModCtrl, Optional: key.ModShift},
+		key.Filter{Name: "I", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "D", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "T", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "T", Required: key.ModCtrl | key.ModAlt},
+		key.Filter{Name: "E", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "H", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "M", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "W", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: "X", Required: key.ModCtrl | key.ModShift},
+		key.Filter{Name: key.NameTab, Required: key.ModCtrl},
+		key.Filter{Name: key.NameF1},
+		key.Filter{Name: key.NameF5},
+		key.Filter{Name: key.NameF7},
+		key.Filter{Name: key.NameF8},
+		key.Filter{Name: key.NameF11},
+	}
+	// The vim keymap takes Escape in the editor itself (see vimEscape), to
+	// leave insert mode before anything else.
+	vimEscape := a.vimEnabled() && gtx.Focused(&a.editor)
+	if !vimEscape && (a.focusMode || a.showHelp || a.showStats || a.showRecent || a.define != nil || a.hasExtraCarets()) {
+		filters = append(filters, key.Filter{Name: key.NameEscape})
+	}
+	if gtx.Focused(&a.editor) && !a.editor.ReadOnly {
+		// Take over plain paste so a pasted URL can wrap the selection and an
+		// image-only clipboard can be saved as a file. A read-only editor
+		// (vim's normal mode) keeps Ctrl+V to itself, and ignores it.
+		filters = append(filters, key.Filter{Name: "V", Required: key.ModCtrl, Optional: key.ModAlt})
+	}
 	for {
-		e, ok := gtx.Event(
-			key.Filter{Focus: &a.keyTag, Name: "S", Required: key.ModCtrl},
-			key.Filter{Focus: &a.keyTag, Name: "O", Required: key.ModCtrl},
-			key.Filter{Focus: &a.keyTag, Name: "N", Required: key.ModCtrl},
-		)
+		e, ok := gtx.Event(filters...)
 		if !ok {
 			break
 		}
@@ -516,11 +1689,109 @@ func (a *App) handleKeys(gtx layout.Context) {
 		}
 		switch ke.Name {
 		case "S":
-			a.saveFile()
+			if ke.Modifiers.Contain(key.ModAlt) {
+				a.promptSortLines()
+			} else if ke.Modifiers.Contain(key.ModShift) {
+				a.promptSaveAs()
+			} else {
+				a.saveFile()
+			}
 		case "O":
-			a.promptOpenFolder()
+			a.promptOpenFolder(ke.Modifiers.Contain(key.ModShift))
 		case "N":
-			a.promptNewFile()
+			if ke.Modifiers.Contain(key.ModShift) {
+				newWindow(false)
+			} else {
+				a.promptNewFile()
+			}
+		case "P":
+			a.printNote()
+		case "J":
+			a.openDailyNote()
+		case "G":
+			if ke.Modifiers.Contain(key.ModShift) {
+				a.promptGitCommit()
+			} else {
+				a.promptGoToLine()
+			}
+		case "[":
+			a.foldAtCaret()
+		case "]":
+			a.unfoldAll()
+		case "C":
+			if ke.Modifiers.Contain(key.ModAlt) {
+				a.copyAsPlainText(gtx)
+			} else {
+				a.copyAsHTML(gtx)
+			}
+		case "V":
+			if ke.Modifiers.Contain(key.ModShift) {
+				a.requestHTMLPaste()
+			} else if ke.Modifiers.Contain(key.ModAlt) {
+				a.requestImagePaste()
+			} else {
+				a.requestPaste(gtx, pasteSmart)
+			}
+		case "R":
+			if ke.Modifiers.Contain(key.ModShift) {
+				a.refreshPreview()
+			} else {
+				a.promptReload()
+			}
+		case "I":
+			a.toggleStats()
+		case "D":
+			a.defineWord()
+		case "T":
+			if ke.Modifiers.Contain(key.ModAlt) {
+				a.transformSelection(titleCase, "Title Case")
+			} else {
+				a.insertTOC()
+			}
+		case "U":
+			a.transformSelection(strings.ToUpper, "UPPERCASE")
+		case "L":
+			if ke.Modifiers.Contain(key.ModShift) {
+				a.revealCurrentFile(gtx)
+			} else {
+				a.transformSelection(strings.ToLower, "lowercase")
+			}
+		case "E":
+			a.toggleRecent()
+		case "H":
+			a.promptExportSite(a.rootPath)
+		case "X":
+			a.promptExportSelection()
+		case "M":
+			a.promptPickCombine()
+		case "W":
+			a.promptWordGoal()
+		case key.NameF5:
+			a.insertDateTime()
+		case key.NameF7:
+			a.swapPanes()
+		case key.NameF8:
+			a.toggleStacked()
+		case key.NameF11:
+			a.toggleFocusMode(gtx)
+		case key.NameTab:
+			a.switchToPrevious()
+		case key.NameF1:
+			a.toggleHelp()
+		case key.NameEscape:
+			if a.hasExtraCarets() {
+				a.dropCarets()
+			} else if a.define != nil {
+				a.define = nil
+			} else if a.showHelp {
+				a.toggleHelp()
+			} else if a.showStats {
+				a.toggleStats()
+			} else if a.showRecent {
+				a.toggleRecent()
+			} else {
+				a.toggleFocusMode(gtx)
+			}
 		}
 	}
 }
@@ -532,7 +1803,7 @@ func (a *App) handleKeys(gtx layout.Context) {
 func (a *App) updateTitle() {
 	if a.currentFile == "" {
 		if a.rootPath != "" {
-			a.window.Option(app.Title("Marknote — " + filepath.Base(a.rootPath)))
+			a.window.Option(app.Title("Marknote — " + filepath.Base(a.rootPath) + a.readOnlyTag()))
 		} else {
 			a.window.Option(app.Title("Marknote"))
 		}
@@ -542,23 +1813,40 @@ func (a *App) updateTitle() {
 	if a.modified {
 		a.window.Option(app.Title("Marknote — " + name + " *"))
 	} else {
-		a.window.Option(app.Title("Marknote — " + name))
+		a.window.Option(app.Title("Marknote — " + name + a.readOnlyTag()))
+	}
+	a.status = a.currentFile + a.readOnlyTag()
+}
+
+// readOnlyTag marks titles and status text while the folder is read-only.
+func (a *App) readOnlyTag() string {
+	if a.readOnly {
+		return " (read-only)"
 	}
-	a.status = a.currentFile
+	return ""
+}
+
+// isDirty reports whether path is the open file and has unsaved changes.
+func (a *App) isDirty(path string) bool {
+	return a.modified && path == a.currentFile
 }
 
-func (a *App) showConfirmModal(title, message string, onOK func(), onCancel func()) {
-	a.modal = &modalState{
+func (a *App) showConfirmModal(title, message string, onOK func(), onCancel func()) *modalState {
+	m := &modalState{
 		kind:     modalConfirm,
 		title:    title,
 		message:  message,
 		onOK:     func(_ string) { onOK() },
 		onCancel: onCancel,
 	}
+	a.modal = m
 	a.window.Invalidate()
+	return m
 }
 
-func (a *App) showInputModal(title, message string, onOK func(string)) {
+// showInputModal shows a modal with a single-line input. The returned state
+// may be adjusted (e.g. options added) before the next frame.
+func (a *App) showInputModal(title, message string, onOK func(string)) *modalState {
 	m := &modalState{
 		kind:    modalInput,
 		title:   title,
@@ -568,6 +1856,7 @@ func (a *App) showInputModal(title, message string, onOK func(string)) {
 	m.input.SingleLine = true
 	a.modal = m
 	a.window.Invalidate()
+	return m
 }
 
 // ---------------------------------------------------------------------------
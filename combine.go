@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ncruces/zenity"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	gmtext "github.com/yuin/goldmark/text"
+)
+
+// ---------------------------------------------------------------------------
+// Combined export
+//
+// Several notes, picked with Ctrl+Shift+M or from a folder's context menu,
+// are joined in tree order into one markdown, HTML or printable (PDF)
+// document. Each note starts at a heading naming it (Config.CombineHeadings)
+// or after a horizontal rule, and a table of contents can lead the document
+// (Config.CombineTOC). Relative links and images are rewritten to resolve
+// from the output's folder, and links between the combined notes point at
+// the heading that starts each note.
+// ---------------------------------------------------------------------------
+
+// combineFormats are the output choices offered, in option order.
+var combineFormats = []string{"Markdown", "HTML", "PDF (print)"}
+
+// promptPickCombine picks notes to combine with the OS file dialog; the
+// choice arrives through combinePickCh.
+func (a *App) promptPickCombine() {
+	dir := a.rootPath
+	if dir == "" {
+		a.status = "Open a folder to combine notes"
+		return
+	}
+	go func() {
+		paths, err := zenity.SelectFileMultiple(
+			zenity.Title("Combine Notes"),
+			zenity.Filename(dir+string(filepath.Separator)),
+			zenity.FileFilter{Name: "Notes", Patterns: []string{"*.md", "*" + a.newFileExt()}, CaseFold: true},
+		)
+		if err != nil || len(paths) == 0 {
+			return
+		}
+		a.combinePickCh <- paths
+		a.window.Invalidate()
+	}()
+}
+
+// treeOrder sorts paths into the order the tree lists them; files outside
+// the open folder keep their order, at the end.
+func (a *App) treeOrder(paths []string) []string {
+	index := map[string]int{}
+	for i, p := range a.collectNotes(a.rootPath) {
+		index[p] = i
+	}
+	rank := func(p string) int {
+		if i, ok := index[p]; ok {
+			return i
+		}
+		return len(index)
+	}
+	sorted := slices.Clone(paths)
+	slices.SortStableFunc(sorted, func(x, y string) int { return rank(x) - rank(y) })
+	return sorted
+}
+
+// promptCombine asks for the combined document's title and format, then
+// where to save it, unless it is to be printed, and writes it in a
+// goroutine; the result arrives through exportCh.
+func (a *App) promptCombine(notes []string, title string) {
+	if len(notes) == 0 {
+		a.status = "No notes to combine"
+		return
+	}
+	if title == "" {
+		title = filepath.Base(a.rootPath)
+	}
+	m := a.showInputModal("Combine Notes", fmt.Sprintf("Title of the document combining %d notes:", len(notes)), nil)
+	m.okLabel = "Combine"
+	m.options = combineFormats
+	m.input.SetText(title)
+	m.onOK = func(title string) {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			a.notifyError(errors.New("title is empty"))
+			return
+		}
+		format := m.option
+		headings, toc := a.cfg.CombineHeadings, a.cfg.CombineTOC
+		md := a.md.plain
+		base := filepath.Dir(notes[0])
+		if a.rootPath != "" {
+			base = a.rootPath
+		}
+		if format == 2 {
+			a.startExport(func() exportResult { return printCombined(md, notes, title, base, headings, toc) })
+			return
+		}
+		ext := ".md"
+		if format == 1 {
+			ext = ".html"
+		}
+		go func() {
+			out, err := zenity.SelectFileSave(
+				zenity.Title("Save Combined Document"),
+				zenity.Filename(filepath.Join(base, title+ext)),
+			)
+			if err != nil || out == "" {
+				return
+			}
+			a.savePickCh <- savePick{targets: []string{out}, write: func() {
+				a.startExport(func() exportResult { return saveCombined(md, notes, title, format, out, headings, toc) })
+			}}
+			a.window.Invalidate()
+		}()
+	}
+}
+
+// printCombined opens the combined document in the browser to print. base
+// is the folder the page resolves its links and images from.
+func printCombined(md goldmark.Markdown, notes []string, title, base string, headings, toc bool) exportResult {
+	doc, err := combineNotes(md, notes, title, base, headings, toc)
+	if err != nil {
+		return exportResult{err: err}
+	}
+	const autoPrint = "<script>window.addEventListener('load', function () { window.print(); });</script>\n"
+	page, err := renderHTMLDocument(md, title, doc, base, autoPrint)
+	if err != nil {
+		return exportResult{err: err}
+	}
+	name, err := writePrintPage("combined-*.html", page)
+	if err == nil {
+		err = openWithSystem(name)
+	}
+	return exportResult{err: err, message: "Opened combined document in browser to print"}
+}
+
+// saveCombined writes the combined document to out, as markdown or, for
+// format 1, HTML.
+func saveCombined(md goldmark.Markdown, notes []string, title string, format int, out string, headings, toc bool) exportResult {
+	doc, err := combineNotes(md, notes, title, filepath.Dir(out), headings, toc)
+	if err != nil {
+		return exportResult{err: err}
+	}
+	data := []byte(doc)
+	if format == 1 {
+		if data, err = renderHTMLDocument(md, title, doc, "", ""); err != nil {
+			return exportResult{err: err}
+		}
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return exportResult{err: err}
+	}
+	return exportResult{message: fmt.Sprintf("Combined %d notes into %s", len(notes), filepath.Base(out))}
+}
+
+// combineNotes joins notes into one markdown document under a title
+// heading, with links and images rebased onto outDir. md parses the notes.
+func combineNotes(md goldmark.Markdown, notes []string, title, outDir string, headings, toc bool) (string, error) {
+	// Links to combined notes first get a placeholder, replaced by the slug
+	// of the note's heading once the whole document is known.
+	placeholders := map[string]string{}
+	if headings {
+		for i, p := range notes {
+			placeholders[p] = fmt.Sprintf("#\x00note%d\x00", i)
+		}
+	}
+
+	head := "# " + title + "\n\n"
+	var body strings.Builder
+	starts := make([]int, len(notes)) // offset of each note's heading text
+	for i, p := range notes {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		if headings {
+			body.WriteString("# ")
+			starts[i] = len(head) + body.Len()
+			body.WriteString(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)) + "\n\n")
+		} else if i > 0 {
+			body.WriteString("---\n\n")
+		}
+		text := strings.TrimSpace(rebaseNote(md, string(data), p, outDir, placeholders))
+		body.WriteString(text + "\n\n")
+	}
+
+	doc := head + body.String()
+	if headings {
+		slugs := headingSlugs(md, doc)
+		for i, p := range notes {
+			doc = strings.ReplaceAll(doc, placeholders[p], "#"+slugs[starts[i]])
+		}
+	}
+	if toc {
+		// The title is the first entry; it heads the page already.
+		if _, list, ok := strings.Cut(buildTOC(md, doc), "\n"); ok && list != "" {
+			doc = head + list + "\n" + strings.TrimPrefix(doc, head)
+		}
+	}
+	return strings.TrimRight(doc, "\n") + "\n", nil
+}
+
+// headingSlugs maps the offset of each top-level heading's text in content
+// to its slug.
+func headingSlugs(md goldmark.Markdown, content string) map[int]string {
+	src := []byte(content)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+	slugs := map[int]string{}
+	s := slugger{}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok && h.Lines().Len() > 0 {
+			slugs[h.Lines().At(0).Start] = s.slug(extractText(h, src))
+		}
+	}
+	return slugs
+}
+
+// rebaseNote rewrites the relative link and image destinations of the note
+// at path, which resolve from its own folder, to resolve from outDir. Links
+// to notes in anchors are replaced by the note's anchor instead.
+func rebaseNote(md goldmark.Markdown, text, path, outDir string, anchors map[string]string) string {
+	src := []byte(text)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+	dests := map[string]string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest string
+		switch n := n.(type) {
+		case *ast.Link:
+			dest = string(n.Destination)
+		case *ast.Image:
+			dest = string(n.Destination)
+		default:
+			return ast.WalkContinue, nil
+		}
+		if nd, ok := rebaseDest(dest, path, outDir, anchors); ok {
+			dests[dest] = nd
+		}
+		return ast.WalkContinue, nil
+	})
+	for dest, nd := range dests {
+		// Inline "](dest" and reference "]: dest" forms.
+		re := regexp.MustCompile(`(\]\(\s*|\]:\s*)` + regexp.QuoteMeta(dest) + `([\s)]|$)`)
+		text = re.ReplaceAllString(text, "${1}"+strings.ReplaceAll(nd, "$", "$$")+"${2}")
+	}
+	return text
+}
+
+// rebaseDest returns dest rebased as rebaseNote describes; it reports false
+// for absolute URLs, fragments and paths it leaves alone.
+func rebaseDest(dest, path, outDir string, anchors map[string]string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || filepath.IsAbs(filepath.FromSlash(u.Path)) {
+		return "", false
+	}
+	abs := filepath.Join(filepath.Dir(path), filepath.FromSlash(u.Path))
+	if anchor, ok := anchors[abs]; ok {
+		if u.Fragment != "" {
+			return "#" + u.Fragment, true
+		}
+		return anchor, true
+	}
+	rel, err := filepath.Rel(outDir, abs)
+	if err != nil {
+		return "", false
+	}
+	return (&url.URL{Path: filepath.ToSlash(rel), Fragment: u.Fragment}).String(), true
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Every "#slug" the combined document links to, from its table of contents
+// or a rewritten link between notes, must be an id in the HTML output.
+func TestCombinedHTMLHeadingIDs(t *testing.T) {
+	dir := t.TempDir()
+	notes := []string{filepath.Join(dir, "First Note.md"), filepath.Join(dir, "second.md")}
+	os.WriteFile(notes[0], []byte("# Intro\n\nSee [the second](second.md).\n\n## Details\n"), 0644)
+	os.WriteFile(notes[1], []byte("# Intro\n\nBack to [the first](First%20Note.md).\n"), 0644)
+
+	md := newMarkdownParsers(flavorAll).plain
+	doc, err := combineNotes(md, notes, "Combined", dir, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := renderHTMLDocument(md, "Combined", doc, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(page)
+	targets := regexp.MustCompile(`href="#([^"]+)"`).FindAllStringSubmatch(out, -1)
+	if len(targets) < 4 {
+		t.Fatalf("expected TOC and note links, got %d in:\n%s", len(targets), out)
+	}
+	for _, m := range targets {
+		if !strings.Contains(out, `id="`+m[1]+`"`) {
+			t.Errorf("no heading with id %q for link #%s", m[1], m[1])
+		}
+	}
+	if strings.Contains(out, "<base") {
+		t.Error("page has a <base> tag, which breaks fragment links")
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config holds user preferences that persist between sessions. It is stored
+// as JSON in the OS user config directory.
+type Config struct {
+	// File tree
+	TreeSort  treeSortOrder `json:"treeSort"`
+	DirsFirst bool          `json:"dirsFirst"`
+	// IgnorePatterns hide matching files and folders, in .gitignore syntax,
+	// on top of any .marknoteignore in the open folder.
+	IgnorePatterns []string `json:"ignorePatterns"`
+	// RespectGitignore also hides files ignored by git in a repository.
+	RespectGitignore bool `json:"respectGitignore"`
+	// ShowGitStatus marks modified, staged and untracked files in the tree
+	// when the folder is in a git work tree.
+	ShowGitStatus bool `json:"showGitStatus"`
+	// GitAutoCommit commits the file to git on every save.
+	GitAutoCommit bool `json:"gitAutoCommit"`
+	// GitAutosave snapshots the open note, unsaved changes included, to the
+	// marknote-autosave branch every GitAutosaveInterval seconds while it
+	// changes, leaving the checked-out branch alone.
+	GitAutosave         bool `json:"gitAutosave"`
+	GitAutosaveInterval int  `json:"gitAutosaveInterval"`
+	// Pins lists pinned notes per folder, as paths relative to it.
+	Pins map[string][]string `json:"pins"`
+	// RememberExpanded keeps the folders expanded in the tree per open
+	// folder, in ExpandedFolders as paths relative to it, and expands them
+	// again when it is next opened. The tree's Collapse button closes all.
+	RememberExpanded bool                `json:"rememberExpanded"`
+	ExpandedFolders  map[string][]string `json:"expandedFolders"`
+	// AnimateTree slides folder contents open and closed.
+	AnimateTree bool `json:"animateTree"`
+
+	// Editor
+	// TabWidth is the number of spaces per indent level, used when
+	// IndentWithSpaces is set and when outdenting. It does not change how
+	// wide a literal tab is drawn: Gio's text shaper has no tab stops, and
+	// widget.Editor offers no hook to lay tabs out differently.
+	TabWidth         int  `json:"tabWidth"`
+	IndentWithSpaces bool `json:"indentWithSpaces"` // Tab inserts spaces instead of '\t'
+	// AutoIndent starts the line Enter opens with the indentation of the
+	// line above.
+	AutoIndent bool `json:"autoIndent"`
+	// DateFormat is the Go time layout used by Insert Date/Time (F5).
+	DateFormat string `json:"dateFormat"`
+	// NewFileExtension is added to new file names that lack one (".md" when
+	// empty); files with it are listed in the tree alongside .md files.
+	NewFileExtension string `json:"newFileExtension"`
+	// NewFileName, when set, names new files without prompting; "{n}" is
+	// replaced with the first free number, as in "Untitled-{n}.md".
+	NewFileName string `json:"newFileName"`
+	// AssetsDir is where pasted images are saved, relative to the note.
+	AssetsDir string `json:"assetsDir"`
+	// ShowMinimap draws a scaled overview of the document beside the editor.
+	ShowMinimap bool `json:"showMinimap"`
+	// ShowRuler draws a vertical guide at RulerColumn characters.
+	ShowRuler   bool `json:"showRuler"`
+	RulerColumn int  `json:"rulerColumn"`
+	// WrapColumn is the width Alt+Q rewraps paragraphs to.
+	WrapColumn int `json:"wrapColumn"`
+	// WordGoal is the word count to aim for in each note, shown with
+	// progress in the status bar; 0 turns it off.
+	WordGoal int `json:"wordGoal"`
+	// EditorLineHeight scales the editor's line spacing, like
+	// PreviewLineHeight; it is held between 1 and 2.5, and 0 keeps Gio's
+	// default of 1.2.
+	EditorLineHeight float32 `json:"editorLineHeight"`
+	// MatchDelimiters shades the bracket, backtick or emphasis marker at the
+	// caret together with its partner on the same line.
+	MatchDelimiters bool `json:"matchDelimiters"`
+	// ReadOnly opens every folder read-only, as Ctrl+Shift+O does for one:
+	// files can be browsed but not edited, saved or created.
+	ReadOnly bool `json:"readOnly"`
+	// Keymap selects the editor key bindings: "default", "vim" or "emacs".
+	Keymap string `json:"keymap"`
+	// DictionaryFile is an offline dictionary for Ctrl+Shift+D, one
+	// "word<TAB>definition" line per sense, consulted before the bundled
+	// word list; empty uses dictionary.tsv beside the config file.
+	DictionaryFile string `json:"dictionaryFile"`
+
+	// Theme is the colour scheme, "light", "dark" or "sepia", as last picked
+	// in the toolbar.
+	Theme string `json:"theme"`
+
+	// Fonts, each a comma-separated list of families tried in order: UIFont
+	// for the interface and preview text, EditorFont for the editor (empty
+	// follows UIFont) and MonoFont for code. Families that are not installed
+	// fall back to the bundled Go and Go Mono.
+	UIFont     string `json:"uiFont"`
+	EditorFont string `json:"editorFont"`
+	MonoFont   string `json:"monoFont"`
+	// FontDir holds extra .ttf/.otf fonts to load at startup, so they can
+	// be named above by family.
+	FontDir string `json:"fontDir"`
+
+	// Preview typography. PreviewMaxWidth (dp) caps the width of the centred
+	// content column, 0 for full width. PreviewLineHeight scales body text
+	// line spacing, 0 for the default.
+	PreviewMaxWidth     float32 `json:"previewMaxWidth"`
+	PreviewBlockSpacing float32 `json:"previewBlockSpacing"` // dp between blocks
+	PreviewLineHeight   float32 `json:"previewLineHeight"`
+	// PreviewImageMaxWidth (dp) caps the width of images in the preview, 0
+	// for the column width.
+	PreviewImageMaxWidth float32 `json:"previewImageMaxWidth"`
+	// SmartQuotes curls straight quotes and shows --, --- and ... as en and
+	// em dashes and an ellipsis in the preview; the file keeps them as typed.
+	SmartQuotes bool `json:"smartQuotes"`
+	// PreviewHTML is what the preview does with HTML in a note: "drop" (or
+	// empty) leaves it out, "text" shows it as typed, and "safe" renders
+	// basic formatting tags such as <b>, <i>, <a> and <br>, dropping others.
+	PreviewHTML string `json:"previewHTML"`
+	// JoinSoftBreaks joins the lines of a paragraph that are wrapped in the
+	// file with a space, as CommonMark does, instead of breaking the line
+	// there. Two trailing spaces or a backslash still break it.
+	JoinSoftBreaks bool `json:"joinSoftBreaks"`
+	// WebLinkClick and NoteLinkClick say how a preview link to a web page
+	// (or anything else with a scheme) and to another note is followed:
+	// "click" on a plain click, or "ctrl+click" only with Ctrl (Cmd on
+	// macOS) held, so clicks made while reading do not navigate away.
+	WebLinkClick  string `json:"webLinkClick"`
+	NoteLinkClick string `json:"noteLinkClick"`
+	// MarkdownFlavor picks the syntax the preview and exports understand:
+	// "commonmark" for none of the extensions, "gfm" for GitHub's tables,
+	// strikethrough, task lists and autolinks, or "all" to add ==mark==,
+	// ^superscript^ and ~subscript~. Also set from the status bar.
+	MarkdownFlavor string `json:"markdownFlavor"`
+	// CodeTheme colours preview code blocks with a bundled scheme, "github",
+	// "monokai", "dracula", "solarized-light" or "solarized-dark",
+	// regardless of the app theme; empty follows the app theme.
+	CodeTheme string `json:"codeTheme"`
+	// PreviewUpdate is when the preview is re-rendered: "live" (or empty) on
+	// every edit, "save" when the file is saved, "manual" only on Ctrl+Shift+R.
+	PreviewUpdate string `json:"previewUpdate"`
+	// Programs that render diagram code blocks in the preview: mermaid-cli
+	// for ```mermaid, PlantUML for ```plantuml and Graphviz for ```dot.
+	MermaidCommand  string `json:"mermaidCommand"`
+	PlantUMLCommand string `json:"plantUMLCommand"`
+	GraphvizCommand string `json:"graphvizCommand"`
+
+	// Pane splits as fractions: the tree's share of the window width, the
+	// editor's share beside the preview, and the first pane's share of the
+	// height when stacked. Dragging a split bar updates them and
+	// double-clicking one resets it.
+	TreeSplit   float32 `json:"treeSplit"`
+	EditorSplit float32 `json:"editorSplit"`
+	StackSplit  float32 `json:"stackSplit"`
+	// PreviewOnLeft swaps the editor and preview panes (F7), putting the
+	// preview on top when stacked.
+	PreviewOnLeft bool `json:"previewOnLeft"`
+	// StackedLayout puts the editor above the preview instead of beside it (F8).
+	StackedLayout bool `json:"stackedLayout"`
+	// NarrowWidth (dp) stacks the panes while the window is narrower than
+	// it, and, with NarrowHidesTree, hides the tree too; 0 disables.
+	NarrowWidth     int  `json:"narrowWidth"`
+	NarrowHidesTree bool `json:"narrowHidesTree"`
+
+	// ShowSavedTime shows when the open file was last written in the status
+	// bar; clicking the label there toggles it.
+	ShowSavedTime bool `json:"showSavedTime"`
+
+	// Saving
+	TrimTrailingWhitespace bool `json:"trimTrailingWhitespace"`
+	EnsureFinalNewline     bool `json:"ensureFinalNewline"` // exactly one '\n' at end of file
+
+	// LineEnding forces "lf" or "crlf" on save; empty keeps each file's own style.
+	LineEnding string `json:"lineEnding"`
+	// PreserveBOM re-adds a UTF-8 BOM on save to files that had one.
+	PreserveBOM bool `json:"preserveBOM"`
+	// RecoveryInterval is how often, in seconds, unsaved changes are
+	// snapshotted for crash recovery; 0 disables snapshots.
+	RecoveryInterval int `json:"recoveryInterval"`
+	// OnSwitch decides what happens to unsaved changes when another file is
+	// opened: "prompt" (or empty) asks, "save" saves first, "discard" drops them.
+	OnSwitch string `json:"onSwitch"`
+	// SaveOnFocusLoss saves unsaved changes when the window loses focus.
+	SaveOnFocusLoss bool `json:"saveOnFocusLoss"`
+	// ConfirmOverwrite asks before Save As or an export replaces files that
+	// already exist.
+	ConfirmOverwrite bool `json:"confirmOverwrite"`
+
+	// Combine Notes (Ctrl+Shift+M): CombineHeadings starts each note at a
+	// heading naming it, instead of a horizontal rule, and CombineTOC puts a
+	// table of contents after the title.
+	CombineHeadings bool `json:"combineHeadings"`
+	CombineTOC      bool `json:"combineTOC"`
+
+	// RecentFiles are the files opened most recently, newest first, up to
+	// RecentFilesMax (0 stops recording them).
+	RecentFiles    []string `json:"recentFiles"`
+	RecentFilesMax int      `json:"recentFilesMax"`
+
+	// Journal: JournalPath is a Go time layout relative to the open folder
+	// ("/"-separated). JournalTemplate accepts the same placeholders as
+	// note templates.
+	JournalPath     string `json:"journalPath"`
+	JournalTemplate string `json:"journalTemplate"`
+}
+
+// Default pane splits, restored by double-clicking a split bar.
+const (
+	defaultTreeSplit   = 0.22
+	defaultEditorSplit = 0.5
+	defaultStackSplit  = 0.5
+)
+
+func defaultConfig() Config {
+	return Config{
+		Theme:      "light",
+		TreeSort:   sortNameAsc,
+		DirsFirst:  true,
+		TabWidth:   4,
+		AutoIndent: true,
+		DateFormat: time.DateOnly,
+		AssetsDir:  "assets",
+
+		RulerColumn:      80,
+		WrapColumn:       80,
+		Keymap:           "default",
+		MatchDelimiters:  true,
+		EditorLineHeight: 1.4,
+
+		PreviewBlockSpacing: 6,
+		PreviewUpdate:       "live",
+		PreserveBOM:         true,
+		ShowSavedTime:       true,
+		ShowGitStatus:       true,
+		NarrowWidth:         760,
+		NarrowHidesTree:     true,
+		OnSwitch:            "prompt",
+		RecoveryInterval:    30,
+		ConfirmOverwrite:    true,
+		GitAutosaveInterval: 120,
+		RememberExpanded:    true,
+
+		PreviewImageMaxWidth: 640,
+		SmartQuotes:          true,
+		PreviewHTML:          htmlDrop,
+		WebLinkClick:         linkClickPlain,
+		NoteLinkClick:        linkClickModified,
+		MarkdownFlavor:       flavorAll,
+
+		RecentFilesMax: 10,
+
+		CombineHeadings: true,
+		CombineTOC:      true,
+
+		TreeSplit:   defaultTreeSplit,
+		EditorSplit: defaultEditorSplit,
+		StackSplit:  defaultStackSplit,
+
+		MermaidCommand:  "mmdc",
+		PlantUMLCommand: "plantuml",
+		GraphvizCommand: "dot",
+
+		JournalPath:     "journal/2006-01-02.md",
+		JournalTemplate: "# {{date}}\n\n",
+	}
+}
+
+// configPath returns the config file location, or "" if the user config
+// directory cannot be determined.
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "marknote", "config.json")
+}
+
+// loadConfig reads the config file. Missing files and missing keys fall back
+// to defaultConfig values. A file that does not parse gives the defaults and
+// the parse error.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+	path := configPath()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return defaultConfig(), fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// configFields returns cfg's values by config key, as JSON.
+func configFields(cfg Config) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	data, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	return fields, err
+}
+
+// saveConfig writes the settings this window changed since it loaded or
+// last saved the config. Each window has its own Config, so the file is read
+// again and only those keys replaced: a pin or recent file saved by another
+// window survives. A file that does not parse is left alone, so a hand edit
+// with a typo is not replaced by the defaults.
+func (a *App) saveConfig() {
+	path := configPath()
+	if path == "" {
+		return
+	}
+	// Settings the open folder overrides are saved with their global values.
+	cfg := a.cfg
+	if err := overlayConfig(&cfg, a.folderGlobals); err != nil {
+		a.notifyError(err)
+		return
+	}
+	fields, err := configFields(cfg)
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+
+	disk := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		a.notifyError(err)
+		return
+	default:
+		if err := json.Unmarshal(data, &disk); err != nil {
+			a.notifyError(fmt.Errorf("config not saved, %s does not parse: %w", path, err))
+			return
+		}
+	}
+	for k, v := range fields {
+		if prev, ok := a.savedConfig[k]; !ok || !bytes.Equal(prev, v) || disk[k] == nil {
+			disk[k] = v
+		}
+	}
+
+	// Decode the merged keys into a Config, so the file keeps its layout and
+	// values of the wrong type are caught before anything is written.
+	merged := defaultConfig()
+	if data, err = json.Marshal(disk); err == nil {
+		err = json.Unmarshal(data, &merged)
+	}
+	if err == nil {
+		data, err = json.MarshalIndent(merged, "", "  ")
+	}
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		a.notifyError(err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.savedConfig = fields
+}
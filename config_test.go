@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useConfigDir points configPath at a fresh directory and returns the config
+// file's path there.
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+	path := configPath()
+	if path == "" || !filepath.IsAbs(path) {
+		t.Skip("no user config directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// A config that does not parse is reported and never overwritten.
+func TestBrokenConfigNotOverwritten(t *testing.T) {
+	path := useConfigDir(t)
+	broken := []byte(`{"theme": "dark",}`)
+	os.WriteFile(path, broken, 0644)
+
+	cfg, err := loadConfig()
+	if err == nil {
+		t.Fatal("no error for a broken config")
+	}
+	a := &App{cfg: cfg, configErr: err}
+	a.saveConfig()
+	if data, _ := os.ReadFile(path); string(data) != string(broken) {
+		t.Errorf("broken config overwritten with %s", data)
+	}
+}
+
+// Two windows saving different settings keep each other's changes.
+func TestSaveConfigMergesWindows(t *testing.T) {
+	useConfigDir(t)
+	newWin := func() *App {
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, _ := configFields(cfg)
+		return &App{cfg: cfg, savedConfig: saved}
+	}
+	a, b := newWin(), newWin()
+	a.cfg.Pins = map[string][]string{"/notes": {"/notes/todo.md"}}
+	a.saveConfig()
+	b.cfg.Theme = "dark"
+	b.saveConfig()
+	a.saveConfig() // a's theme is unchanged, so b's stays
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Pins["/notes"]) != 1 {
+		t.Errorf("pins lost: %v", cfg.Pins)
+	}
+	if cfg.Theme != "dark" {
+		t.Errorf("theme = %q, want dark", cfg.Theme)
+	}
+}
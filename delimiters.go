@@ -0,0 +1,203 @@
+package main
+
+import (
+	"image"
+	"unicode"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Matching delimiters
+//
+// With the caret beside a bracket, backtick or emphasis marker, the marker
+// and its partner on the same line are shaded. The editor only reports the
+// caret's position, so the partner's is found by measuring the text between
+// them; lines long enough to wrap are skipped, since a wrap would throw the
+// measurement off.
+// ---------------------------------------------------------------------------
+
+// delimiterKey identifies what a delimiterCache was measured for.
+type delimiterKey struct {
+	line    string
+	col     int
+	width   int
+	pxPerSp float32
+}
+
+// delimiterCache holds the shaded x ranges, relative to the caret, for the
+// last caret line measured.
+type delimiterCache struct {
+	key    delimiterKey
+	ranges [2][2]int
+	ok     bool
+}
+
+var bracketPairs = map[rune]rune{'(': ')', '[': ']', ')': '(', ']': '['}
+
+// matchDelimiter finds the delimiter run touching col in line (the rune
+// before col first) and its partner, as [start, end) column ranges.
+func matchDelimiter(line []rune, col int) (from, to [2]int, ok bool) {
+	for _, i := range []int{col - 1, col} {
+		if i < 0 || i >= len(line) {
+			continue
+		}
+		switch c := line[i]; c {
+		case '(', '[', ')', ']':
+			if j := matchBracket(line, i); j >= 0 {
+				return [2]int{i, i + 1}, [2]int{j, j + 1}, true
+			}
+		case '`', '*', '_':
+			if s, e, ok := delimiterRun(line, i); ok {
+				if m, ok := matchRun(line, s, e); ok {
+					return [2]int{s, e}, m, true
+				}
+			}
+		}
+	}
+	return from, to, false
+}
+
+// matchBracket returns the index of the bracket balancing line[i], or -1.
+func matchBracket(line []rune, i int) int {
+	open, close, step := line[i], bracketPairs[line[i]], 1
+	if open == ')' || open == ']' {
+		step = -1
+	}
+	depth := 0
+	for j := i; j >= 0 && j < len(line); j += step {
+		switch line[j] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// delimiterRun returns the run of line[i]'s character around i, unless it
+// cannot open or close a span: spaced on both sides like a "* " bullet, or
+// an underscore inside a word.
+func delimiterRun(line []rune, i int) (start, end int, ok bool) {
+	c := line[i]
+	start, end = i, i+1
+	for start > 0 && line[start-1] == c {
+		start--
+	}
+	for end < len(line) && line[end] == c {
+		end++
+	}
+	spaceAt := func(j int) bool { return j < 0 || j >= len(line) || unicode.IsSpace(line[j]) }
+	wordAt := func(j int) bool {
+		return j >= 0 && j < len(line) && (unicode.IsLetter(line[j]) || unicode.IsDigit(line[j]))
+	}
+	if c != '`' && spaceAt(start-1) && spaceAt(end) {
+		return 0, 0, false
+	}
+	if c == '_' && wordAt(start-1) && wordAt(end) {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// matchRun pairs the run [s, e) with a neighbouring run of the same length,
+// counting runs from the start of the line: even ones open, odd ones close.
+func matchRun(line []rune, s, e int) ([2]int, bool) {
+	var runs [][2]int
+	idx := -1
+	for i := 0; i < len(line); {
+		if line[i] != line[s] {
+			i++
+			continue
+		}
+		rs, re, ok := delimiterRun(line, i)
+		if ok && re-rs == e-s {
+			if rs == s {
+				idx = len(runs)
+			}
+			runs = append(runs, [2]int{rs, re})
+		}
+		i = max(re, i+1)
+	}
+	partner := idx + 1
+	if idx%2 == 1 {
+		partner = idx - 1
+	}
+	if idx < 0 || partner >= len(runs) {
+		return [2]int{}, false
+	}
+	return runs[partner], true
+}
+
+// drawDelimiterMatch shades the delimiter at the caret and its partner,
+// behind the editor text.
+func (a *App) drawDelimiterMatch(gtx layout.Context, ed material.EditorStyle) {
+	start, end := a.editor.Selection()
+	if start != end {
+		return
+	}
+	runes := []rune(a.editor.Text())
+	if start > len(runes) {
+		return
+	}
+	ls, le := lineStart(runes, start), lineEnd(runes, start)
+	key := delimiterKey{line: string(runes[ls:le]), col: start - ls, width: gtx.Constraints.Max.X, pxPerSp: gtx.Metric.PxPerSp}
+	if a.delims.key != key {
+		a.delims = a.measureDelimiters(gtx, ed, runes[ls:le], key)
+	}
+	if !a.delims.ok {
+		return
+	}
+
+	caret := a.editor.CaretCoords().Round()
+	em := gtx.Sp(ed.TextSize)
+	shade := mulAlpha(a.th.Palette.ContrastBg, 70)
+	for _, r := range a.delims.ranges {
+		rect := image.Rect(caret.X+r[0], caret.Y-em*95/100, caret.X+r[1], caret.Y+em*25/100)
+		paint.FillShape(gtx.Ops, shade, clip.Rect(rect).Op())
+	}
+}
+
+// measureDelimiters finds the caret line's delimiter pair and measures its x
+// ranges relative to the caret.
+func (a *App) measureDelimiters(gtx layout.Context, ed material.EditorStyle, line []rune, key delimiterKey) delimiterCache {
+	c := delimiterCache{key: key}
+	from, to, ok := matchDelimiter(line, key.col)
+	if !ok {
+		return c
+	}
+	measure := func(s string) int {
+		rec := op.Record(gtx.Ops)
+		lgtx := gtx
+		lgtx.Constraints = layout.Constraints{Max: image.Pt(1<<20, 1<<20)}
+		lbl := material.Label(a.th, ed.TextSize, s)
+		lbl.MaxLines = 1
+		lbl.Font = ed.Font
+		w := lbl.Layout(lgtx).Size.X
+		rec.Stop()
+		return w
+	}
+	// Bracket with x's so leading and trailing spaces are measured too.
+	xx := measure("xx")
+	width := func(s []rune) int { return measure("x"+string(s)+"x") - xx }
+	if width(line) > key.width {
+		return c // wraps
+	}
+	x := func(col int) int {
+		if col >= key.col {
+			return width(line[key.col:col])
+		}
+		return -width(line[col:key.col])
+	}
+	c.ranges = [2][2]int{{x(from[0]), x(from[1])}, {x(to[0]), x(to[1])}}
+	c.ok = true
+	return c
+}
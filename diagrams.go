@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Diagrams
+//
+// Fenced code blocks in a diagram language (mermaid, plantuml, dot) are
+// rendered to PNG by the language's command-line tool, in a goroutine, and
+// drawn like images. Until the render finishes, and for good if the tool is
+// missing or fails, the block shows its source as code. Renders are cached by
+// a hash of the source, so live preview updates re-run the tool only for
+// diagrams that changed, and only once edits have settled. Each diagram, by
+// its place among the note's diagrams, has at most one render running; the
+// cache keeps only the diagrams still in the preview.
+// ---------------------------------------------------------------------------
+
+// diagramTimeout bounds one run of a diagram tool.
+const diagramTimeout = 30 * time.Second
+
+// diagramSettle is how long after an edit diagrams wait to render, so typing
+// in a diagram block does not start a tool run per keystroke.
+const diagramSettle = 750 * time.Millisecond
+
+// diagramTool describes how to run the renderer for one language. The source,
+// passed through prepare if set, is written to input in a scratch directory;
+// the tool must leave diagram.png beside it.
+type diagramTool struct {
+	input   string
+	command func(c *Config) string
+	args    func(in, out string) []string
+	prepare func(source string) string
+}
+
+var diagramTools = map[string]diagramTool{
+	"mermaid": {
+		input:   "diagram.mmd",
+		command: func(c *Config) string { return c.MermaidCommand },
+		args: func(in, out string) []string {
+			return []string{"-i", in, "-o", out, "-b", "white", "-q"}
+		},
+	},
+	"plantuml": {
+		input:   "diagram.puml", // plantuml names its output after the input
+		command: func(c *Config) string { return c.PlantUMLCommand },
+		args:    func(in, out string) []string { return []string{"-tpng", in} },
+		prepare: func(source string) string {
+			if strings.HasPrefix(strings.TrimSpace(source), "@start") {
+				return source
+			}
+			return "@startuml\n" + source + "\n@enduml\n"
+		},
+	},
+	"dot":      graphvizTool,
+	"graphviz": graphvizTool,
+}
+
+var graphvizTool = diagramTool{
+	input:   "diagram.dot",
+	command: func(c *Config) string { return c.GraphvizCommand },
+	args:    func(in, out string) []string { return []string{"-Tpng", "-o", out, in} },
+}
+
+// diagramBlock is a fenced code block the preview draws as a diagram once
+// it has been rendered.
+type diagramBlock struct {
+	lang  string
+	key   string // cache key: hash of lang and source
+	code  codeBlock
+	image imageBlock // image.img is set by the preview pane once rendered
+}
+
+// diagramResult is a finished render, delivered to the frame loop.
+type diagramResult struct {
+	key  string
+	slot int // the diagram's place among the preview's diagrams
+	img  *previewImage
+}
+
+func newDiagramBlock(lang, source string) *diagramBlock {
+	sum := sha256.Sum256([]byte(lang + "\x00" + source))
+	return &diagramBlock{
+		lang:  lang,
+		key:   hex.EncodeToString(sum[:]),
+		code:  codeBlock{code: source},
+		image: imageBlock{dest: lang, alt: lang + " diagram"},
+	}
+}
+
+func (b *diagramBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
+	if img := b.image.img; img != nil && img.err == nil {
+		return b.image.Layout(gtx, th, st)
+	}
+	return b.code.Layout(gtx, th, st)
+}
+
+// diagramImage returns the rendered diagram for b, the slot'th diagram in
+// the preview, starting a render if none is running for that slot and edits
+// have settled. It returns nil until the render is done.
+func (a *App) diagramImage(gtx layout.Context, b *diagramBlock, slot int) *previewImage {
+	if img, ok := a.diagrams[b.key]; ok {
+		return img
+	}
+	if _, busy := a.diagramRuns[slot]; busy {
+		return nil // applyDiagram invalidates once it finishes
+	}
+	if gtx.Now.Before(a.diagramsAfter) {
+		gtx.Execute(op.InvalidateCmd{At: a.diagramsAfter})
+		return nil
+	}
+	if a.diagramRuns == nil {
+		a.diagramRuns = make(map[int]string)
+	}
+	a.diagramRuns[slot] = b.key
+	tool := diagramTools[b.lang]
+	command := tool.command(&a.cfg)
+	source := b.code.code
+	key := b.key
+	a.tasks++
+	go func() {
+		a.diagramCh <- diagramResult{key: key, slot: slot, img: renderDiagram(tool, command, source)}
+		a.window.Invalidate()
+	}()
+	return nil
+}
+
+// applyDiagram stores a finished render, unless its source has left the
+// preview since it started.
+func (a *App) applyDiagram(res diagramResult) {
+	a.tasks--
+	delete(a.diagramRuns, res.slot)
+	if !slices.ContainsFunc(a.previewBlocks, func(b renderedBlock) bool {
+		d, ok := b.(*diagramBlock)
+		return ok && d.key == res.key
+	}) {
+		return
+	}
+	if res.img.err != nil {
+		a.status = "Diagram not rendered: " + res.img.err.Error()
+	}
+	if a.diagrams == nil {
+		a.diagrams = make(map[string]*previewImage)
+	}
+	a.diagrams[res.key] = res.img
+}
+
+// renderDiagram runs tool on source in a scratch directory and decodes the
+// PNG it produces.
+func renderDiagram(tool diagramTool, command, source string) *previewImage {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return &previewImage{err: fmt.Errorf("%s is not installed", command)}
+	}
+	dir, err := os.MkdirTemp("", "marknote-diagram-")
+	if err != nil {
+		return &previewImage{err: err}
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, tool.input)
+	out := filepath.Join(dir, "diagram.png")
+	if tool.prepare != nil {
+		source = tool.prepare(source)
+	}
+	if err := os.WriteFile(in, []byte(source), 0644); err != nil {
+		return &previewImage{err: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), diagramTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, tool.args(in, out)...)
+	cmd.Dir = dir
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		if first, _, _ := strings.Cut(strings.TrimSpace(string(msg)), "\n"); first != "" {
+			err = errors.New(first)
+		}
+		return &previewImage{err: fmt.Errorf("%s: %w", filepath.Base(command), err)}
+	}
+	return decodeImage(out)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// maxSenses caps how many definitions of one word the popup lists.
+const maxSenses = 5
+
+// bundledDictionary is a small list of common words, used when the user's
+// dictionary file is missing or has no entry for the word.
+//
+//go:embed dictionary.tsv
+var bundledDictionary string
+
+// definition is a dictionary lookup for the word at the caret, shown in a
+// popup below it until the caret moves.
+type definition struct {
+	word   string
+	senses []string
+	err    error
+	caret  int // caret offset the lookup was made at
+}
+
+// dictionaryPath returns the user's dictionary file, which is consulted
+// before the bundled one: Config.DictionaryFile, or dictionary.tsv beside the
+// config file.
+func (a *App) dictionaryPath() string {
+	if a.cfg.DictionaryFile != "" {
+		return a.cfg.DictionaryFile
+	}
+	if p := configPath(); p != "" {
+		return filepath.Join(filepath.Dir(p), "dictionary.tsv")
+	}
+	return ""
+}
+
+// defineWord looks up the word at the caret in a goroutine; the result is
+// delivered through defineCh and shown as a popup.
+func (a *App) defineWord() {
+	runes := []rune(a.editor.Text())
+	caret, _ := a.editor.Selection()
+	w := wordAt(runes, caret)
+	if w == "" {
+		a.status = "No word at the caret"
+		return
+	}
+	path := a.dictionaryPath()
+	a.tasks++
+	go func() {
+		senses, err := lookupDefinition(path, w)
+		a.defineCh <- definition{word: w, senses: senses, err: err, caret: caret}
+		a.window.Invalidate()
+	}()
+}
+
+// wordAt returns the word containing or ending at pos, "" if there is none.
+// Apostrophes and hyphens inside a word are part of it.
+func wordAt(runes []rune, pos int) string {
+	isWord := func(i int) bool {
+		if i < 0 || i >= len(runes) {
+			return false
+		}
+		r := runes[i]
+		if r == '\'' || r == '-' {
+			return isLetterAt(runes, i-1) && isLetterAt(runes, i+1)
+		}
+		return unicode.IsLetter(r)
+	}
+	start, end := pos, pos
+	for isWord(start - 1) {
+		start--
+	}
+	for isWord(end) {
+		end++
+	}
+	return string(runes[start:end])
+}
+
+func isLetterAt(runes []rune, i int) bool {
+	return i >= 0 && i < len(runes) && unicode.IsLetter(runes[i])
+}
+
+// lookupDefinition looks word up in the dictionary at path, falling back to
+// the bundled dictionary when that file is missing or has no entry for it.
+func lookupDefinition(path, word string) ([]string, error) {
+	if path != "" {
+		f, err := os.Open(path)
+		if err == nil {
+			senses, err := scanDictionary(f, word)
+			f.Close()
+			if err != nil || len(senses) > 0 {
+				return senses, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return scanDictionary(strings.NewReader(bundledDictionary), word)
+}
+
+// scanDictionary scans a tab-separated dictionary ("word<TAB>definition",
+// one sense per line) for word, ignoring case.
+func scanDictionary(r io.Reader, word string) ([]string, error) {
+	var senses []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		head, def, ok := strings.Cut(sc.Text(), "\t")
+		if !ok || !strings.EqualFold(head, word) {
+			continue
+		}
+		senses = append(senses, strings.TrimSpace(def))
+		if len(senses) == maxSenses {
+			break
+		}
+	}
+	return senses, sc.Err()
+}
+
+// applyDefinition shows a finished lookup, or reports why there is none.
+func (a *App) applyDefinition(d definition) {
+	a.tasks--
+	switch {
+	case d.err != nil:
+		a.notifyError(d.err)
+	case len(d.senses) == 0:
+		a.status = "No definition for “" + d.word + "”"
+	default:
+		a.define = &d
+	}
+}
+
+// layoutDefinition draws the definition popup just below the caret, deferred
+// so it paints over the neighbouring panes. It closes once the caret moves.
+func (a *App) layoutDefinition(gtx layout.Context) {
+	d := a.define
+	if caret, _ := a.editor.Selection(); caret != d.caret {
+		a.define = nil
+		return
+	}
+	pos := a.editor.CaretCoords().Round()
+	pos.Y += gtx.Sp(unit.Sp(20))
+
+	rec := op.Record(gtx.Ops)
+	cgtx := gtx
+	cgtx.Constraints = layout.Constraints{Max: image.Pt(gtx.Dp(320), gtx.Dp(400))}
+	dims := layout.UniformInset(unit.Dp(10)).Layout(cgtx, func(gtx layout.Context) layout.Dimensions {
+		children := []layout.FlexChild{
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Label(a.th, unit.Sp(14), d.word)
+				lbl.Font.Weight = font.Bold
+				return lbl.Layout(gtx)
+			}),
+		}
+		for i, s := range d.senses {
+			text := s
+			if len(d.senses) > 1 {
+				text = string(rune('1'+i)) + ". " + s
+			}
+			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, material.Body2(a.th, text).Layout)
+			}))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	})
+	call := rec.Stop()
+
+	// Keep the card inside the pane horizontally.
+	pos.X = max(0, min(pos.X, gtx.Constraints.Max.X-dims.Size.X))
+	rec = op.Record(gtx.Ops)
+	off := op.Offset(pos).Push(gtx.Ops)
+	r := clip.UniformRRect(image.Rectangle{Max: dims.Size}, gtx.Dp(4))
+	paint.FillShape(gtx.Ops, a.th.Palette.Bg, r.Op(gtx.Ops))
+	paint.FillShape(gtx.Ops, mulAlpha(a.th.Palette.Fg, 60), clip.Stroke{Path: r.Path(gtx.Ops), Width: float32(gtx.Dp(1))}.Op())
+	call.Add(gtx.Ops)
+	off.Pop()
+	op.Defer(gtx.Ops, rec.Stop())
+}
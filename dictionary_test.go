@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The user's dictionary overrides the bundled one for the words it has, and
+// the bundled list answers the rest, even when the user's file is missing.
+func TestLookupDefinitionOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dictionary.tsv")
+	if err := os.WriteFile(path, []byte("Note\tmy own sense\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path, word, want string
+	}{
+		{path, "note", "my own sense"},
+		{path, "Draft", "a preliminary version of a piece of writing"},
+		{filepath.Join(t.TempDir(), "missing.tsv"), "draft", "a preliminary version of a piece of writing"},
+	} {
+		senses, err := lookupDefinition(tc.path, tc.word)
+		if err != nil {
+			t.Fatalf("lookup %q: %v", tc.word, err)
+		}
+		if len(senses) == 0 || senses[0] != tc.want {
+			t.Errorf("lookup %q = %q, want first sense %q", tc.word, senses, tc.want)
+		}
+	}
+	if senses, _ := lookupDefinition("", "xyzzy"); len(senses) != 0 {
+		t.Errorf("lookup of an unknown word = %q", senses)
+	}
+}
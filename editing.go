@@ -0,0 +1,544 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ---------------------------------------------------------------------------
+// Buffer helpers
+//
+// widget.Editor works in rune offsets, so these helpers operate on []rune
+// copies of the text and write changes back through replaceRange, which goes
+// through the editor's undo history.
+// ---------------------------------------------------------------------------
+
+// orderedSelection returns the editor selection with start <= end.
+func (a *App) orderedSelection() (start, end int) {
+	start, end = a.editor.Selection()
+	if start > end {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// replaceRange replaces runes [start, end) with s. Extra carets are dropped,
+// as the edit is not one to repeat at them.
+func (a *App) replaceRange(start, end int, s string) {
+	a.dropCarets()
+	a.editor.SetCaret(start, end)
+	a.editor.Insert(s)
+}
+
+// lineStart returns the offset of the first rune of the line containing pos.
+func lineStart(runes []rune, pos int) int {
+	for pos > 0 && runes[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// lineEnd returns the offset of the newline ending the line containing pos,
+// or len(runes) for the last line.
+func lineEnd(runes []rune, pos int) int {
+	for pos < len(runes) && runes[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// offsetToLineCol converts a rune offset into 0-based line and column.
+func offsetToLineCol(lines []string, pos int) (line, col int) {
+	for i, l := range lines {
+		n := len([]rune(l))
+		if pos <= n || i == len(lines)-1 {
+			return i, pos
+		}
+		pos -= n + 1
+	}
+	return 0, 0
+}
+
+// lineColToOffset converts a 0-based line and column into a rune offset.
+func lineColToOffset(lines []string, line, col int) int {
+	pos := 0
+	for i := 0; i < line && i < len(lines); i++ {
+		pos += len([]rune(lines[i])) + 1
+	}
+	return pos + col
+}
+
+// selectedLines returns the span of whole lines touched by the selection.
+// A selection ending at the start of a line does not include that line.
+func (a *App) selectedLines(runes []rune) (start, end int) {
+	selStart, selEnd := a.orderedSelection()
+	if selEnd > selStart && runes[selEnd-1] == '\n' {
+		selEnd--
+	}
+	return lineStart(runes, selStart), lineEnd(runes, selEnd)
+}
+
+// ---------------------------------------------------------------------------
+// Indentation
+// ---------------------------------------------------------------------------
+
+// indentUnit is the text inserted by one press of Tab.
+func (a *App) indentUnit() string {
+	if a.cfg.IndentWithSpaces {
+		return strings.Repeat(" ", a.tabWidth())
+	}
+	return "\t"
+}
+
+// tabWidth is Config.TabWidth, or 4 if unset. It sizes space indents only;
+// see Config.TabWidth.
+func (a *App) tabWidth() int {
+	if a.cfg.TabWidth <= 0 {
+		return 4
+	}
+	return a.cfg.TabWidth
+}
+
+// outdentLine strips one level of indentation from the start of line and
+// returns the result and the number of runes removed.
+func (a *App) outdentLine(line string) (string, int) {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:], 1
+	}
+	n := 0
+	for n < len(line) && n < a.tabWidth() && line[n] == ' ' {
+		n++
+	}
+	return line[n:], n
+}
+
+// indentSelection handles Tab and Shift+Tab. Tab with no selection inserts one
+// indent unit at the caret; otherwise every selected line is indented or
+// outdented and the selection is widened to cover the changed lines.
+func (a *App) indentSelection(outdent bool) {
+	if a.denyReadOnly() {
+		return
+	}
+	selStart, selEnd := a.orderedSelection()
+	if !outdent && selStart == selEnd {
+		a.editor.Insert(a.indentUnit())
+		return
+	}
+
+	a.unfoldLines(selStart, selEnd)
+	runes := []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	lines := strings.Split(string(runes[start:end]), "\n")
+	removedFirst := 0
+	for i, line := range lines {
+		if outdent {
+			var n int
+			lines[i], n = a.outdentLine(line)
+			if i == 0 {
+				removedFirst = n
+			}
+		} else {
+			lines[i] = a.indentUnit() + line
+		}
+	}
+	block := strings.Join(lines, "\n")
+	a.replaceRange(start, end, block)
+
+	if selStart == selEnd {
+		// Outdenting the caret line: keep the caret on the same character.
+		caret := selStart - removedFirst
+		if caret < start {
+			caret = start
+		}
+		a.editor.SetCaret(caret, caret)
+		return
+	}
+	a.editor.SetCaret(start, start+len([]rune(block)))
+}
+
+// newlineWithIndent replaces the selection with a line break followed by the
+// leading whitespace of the line it starts on, up to the caret.
+func (a *App) newlineWithIndent() {
+	runes := []rune(a.editor.Text())
+	start, _ := a.orderedSelection()
+	from := lineStart(runes, start)
+	to := from
+	for to < start && (runes[to] == ' ' || runes[to] == '\t') {
+		to++
+	}
+	a.editor.Insert("\n" + string(runes[from:to]))
+}
+
+// ---------------------------------------------------------------------------
+// Line moves
+// ---------------------------------------------------------------------------
+
+// moveLines swaps the lines touched by the selection with the line above
+// (dir < 0) or below, keeping the selection on the moved text.
+func (a *App) moveLines(dir int) {
+	runes := []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	selStart, selEnd := a.editor.Selection()
+	block := string(runes[start:end])
+	var shift int
+	if dir < 0 {
+		if start == 0 {
+			return
+		}
+		above := lineStart(runes, start-1)
+		a.replaceRange(above, end, block+"\n"+string(runes[above:start-1]))
+		shift = above - start
+	} else {
+		if end == len(runes) {
+			return
+		}
+		below := lineEnd(runes, end+1)
+		a.replaceRange(start, below, string(runes[end+1:below])+"\n"+block)
+		shift = below - end
+	}
+	a.editor.SetCaret(selStart+shift, selEnd+shift)
+}
+
+// duplicateLines inserts a copy of the lines touched by the selection below
+// them and moves the selection onto the copy.
+func (a *App) duplicateLines() {
+	runes := []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	selStart, selEnd := a.editor.Selection()
+	a.replaceRange(end, end, "\n"+string(runes[start:end]))
+	shift := end - start + 1
+	a.editor.SetCaret(selStart+shift, selEnd+shift)
+}
+
+// joinLines joins the lines touched by the selection, or the caret's line
+// and the next, into one. The whitespace around each line break collapses
+// to a single space, or to nothing where a line is blank.
+func (a *App) joinLines() {
+	// The caret's line may be joined with the next one, so unfold that too.
+	runes := []rune(a.editor.Text())
+	selStart, selEnd := a.orderedSelection()
+	a.unfoldLines(selStart, min(lineEnd(runes, selEnd)+1, len(runes)))
+	runes = []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	single := lineEnd(runes, start) == end
+	if single {
+		if end == len(runes) {
+			return
+		}
+		end = lineEnd(runes, end+1)
+	}
+	lines := strings.Split(string(runes[start:end]), "\n")
+	joined := strings.TrimRightFunc(lines[0], unicode.IsSpace)
+	at := len([]rune(joined)) // where the caret's line met the next
+	for _, l := range lines[1:] {
+		l = strings.TrimSpace(l)
+		if joined != "" && l != "" {
+			joined += " "
+		}
+		joined += l
+	}
+	a.replaceRange(start, end, joined)
+	if single {
+		a.editor.SetCaret(start+at, start+at)
+	} else {
+		a.editor.SetCaret(start, start+len([]rune(joined)))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Sorting
+// ---------------------------------------------------------------------------
+
+// sortOrders are the choices promptSortLines offers, in option order.
+var sortOrders = []string{"A to Z", "Z to A", "A to Z, ignoring case", "Z to A, ignoring case"}
+
+// promptSortLines asks which order to sort the selected lines in.
+func (a *App) promptSortLines() {
+	if a.denyReadOnly() {
+		return
+	}
+	runes := []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	if lineEnd(runes, start) == end {
+		a.status = "Select several lines to sort them"
+		return
+	}
+	n := strings.Count(string(runes[start:end]), "\n") + 1
+	var m *modalState
+	m = a.showConfirmModal("Sort Lines", fmt.Sprintf("Sort %d lines:", n), func() {
+		a.sortLines(m.option%2 == 1, m.option >= 2)
+	}, nil)
+	m.okLabel = "Sort"
+	m.options = sortOrders
+}
+
+// sortLines sorts the lines touched by the selection, in reverse when desc,
+// and keeps them selected. With fold, lines differing only in case compare
+// equal and keep their order. A trailing newline in the selection stays
+// where it was, as it is not part of the lines.
+func (a *App) sortLines(desc, fold bool) {
+	a.unfoldLines(a.orderedSelection())
+	runes := []rune(a.editor.Text())
+	start, end := a.selectedLines(runes)
+	lines := strings.Split(string(runes[start:end]), "\n")
+	key := func(s string) string { return s }
+	if fold {
+		key = strings.ToLower
+	}
+	slices.SortStableFunc(lines, func(x, y string) int {
+		if desc {
+			x, y = y, x
+		}
+		return strings.Compare(key(x), key(y))
+	})
+	a.replaceRange(start, end, strings.Join(lines, "\n"))
+	a.editor.SetCaret(start, end)
+	a.status = fmt.Sprintf("Sorted %d lines", len(lines))
+}
+
+// ---------------------------------------------------------------------------
+// Reflow
+//
+// Alt+Q hard-wraps the paragraph at the caret to Config.WrapColumn and
+// Alt+Shift+Q unwraps it onto one line. A paragraph ends at a blank line, a
+// heading, fence, table row or rule, and at the next list item, so list items
+// are rewrapped one at a time with their continuation lines indented under
+// the text. Blockquote markers are kept on every line.
+// ---------------------------------------------------------------------------
+
+var (
+	// reflowQuoteRe matches a line's blockquote markers.
+	reflowQuoteRe = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)+`)
+	// reflowItemRe matches a list item's marker, with any task box, and the
+	// indentation before it.
+	reflowItemRe = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])(?:[ \t]+\[[ xX]\])?(?:[ \t]+|$)`)
+	// reflowFixedRe matches lines that never join a paragraph.
+	reflowFixedRe = regexp.MustCompile("^[ \t]*(?:#{1,6}(?:[ \t]|$)|```|~~~|\\||<|(?:[-*_][ \t]*){3,}$)")
+	// reflowMarkerRe matches words that would start a list item, heading or
+	// quote if a wrapped line began with them.
+	reflowMarkerRe = regexp.MustCompile(`^(?:[-*+>]|#{1,6}|\d{1,9}[.)])$`)
+)
+
+func (a *App) wrapColumn() int {
+	if a.cfg.WrapColumn <= 0 {
+		return 80
+	}
+	return a.cfg.WrapColumn
+}
+
+// reflowParagraph rewraps the paragraph at the caret to the wrap column, or
+// joins it onto one line when unwrap is set.
+func (a *App) reflowParagraph(unwrap bool) {
+	if a.denyReadOnly() {
+		return
+	}
+	lines := strings.Split(a.editor.Text(), "\n")
+	caret, _ := a.editor.Selection()
+	line, _ := offsetToLineCol(lines, caret)
+	first, last, ok := paragraphAt(lines, line)
+	if !ok {
+		a.status = "No paragraph at the caret"
+		return
+	}
+	// Rewrapping a fold placeholder would lose the folded lines: unfold the
+	// paragraph and find it again in the real text.
+	before := a.editor.Text()
+	a.unfoldLines(lineColToOffset(lines, first, 0), lineColToOffset(lines, last, 0))
+	if a.editor.Text() != before {
+		lines = strings.Split(a.editor.Text(), "\n")
+		caret, _ = a.editor.Selection()
+		line, _ = offsetToLineCol(lines, caret)
+		if first, last, ok = paragraphAt(lines, line); !ok {
+			a.status = "No paragraph at the caret"
+			return
+		}
+	}
+	width := a.wrapColumn()
+	if unwrap {
+		width = 0
+	}
+	text := rewrap(lines[first:last+1], width)
+	start := lineColToOffset(lines, first, 0)
+	end := lineColToOffset(lines, last, len([]rune(lines[last])))
+	a.replaceRange(start, end, text)
+	end = start + len([]rune(text))
+	a.editor.SetCaret(end, end)
+	if unwrap {
+		a.status = "Unwrapped paragraph"
+	} else {
+		a.status = fmt.Sprintf("Rewrapped paragraph to %d columns", width)
+	}
+}
+
+// splitQuote splits a line into its blockquote markers and the rest.
+func splitQuote(line string) (quote, rest string) {
+	n := len(reflowQuoteRe.FindString(line))
+	return line[:n], line[n:]
+}
+
+// paragraphAt returns the first and last lines of the paragraph containing
+// line, reporting false when line is not part of one.
+func paragraphAt(lines []string, line int) (first, last int, ok bool) {
+	quote, _ := splitQuote(lines[line])
+	// joins reports whether line i can belong to the same paragraph.
+	joins := func(i int) bool {
+		q, rest := splitQuote(lines[i])
+		return q == quote && strings.TrimSpace(rest) != "" && !reflowFixedRe.MatchString(rest)
+	}
+	isItem := func(i int) bool {
+		_, rest := splitQuote(lines[i])
+		return reflowItemRe.MatchString(rest)
+	}
+	if !joins(line) {
+		return 0, 0, false
+	}
+	first, last = line, line
+	for first > 0 && !isItem(first) && joins(first-1) {
+		first--
+	}
+	for last+1 < len(lines) && !isItem(last+1) && joins(last+1) {
+		last++
+	}
+	return first, last, true
+}
+
+// rewrap joins a paragraph's words and breaks them into lines no wider than
+// width runes where possible, or onto one line when width is 0. The first
+// line keeps its prefix; the others get the blockquote markers and an indent
+// lining them up with the first line's text.
+func rewrap(lines []string, width int) string {
+	quote, rest := splitQuote(lines[0])
+	marker := reflowItemRe.FindString(rest)
+	if marker == "" {
+		marker = rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	}
+	indent := marker
+	if reflowItemRe.MatchString(rest) {
+		indent = strings.Repeat(" ", len([]rune(strings.TrimRight(marker, " \t")))+1)
+	}
+	words := strings.Fields(rest[len(marker):])
+	for _, l := range lines[1:] {
+		_, r := splitQuote(l)
+		words = append(words, strings.Fields(r)...)
+	}
+
+	var b strings.Builder
+	b.WriteString(quote + marker)
+	col := len([]rune(quote + marker))
+	lineStart := true
+	for _, w := range words {
+		n := len([]rune(w))
+		// A word that would read as markup at the start of a line stays on
+		// the line before, however long it gets.
+		if width > 0 && !lineStart && col+1+n > width && !reflowMarkerRe.MatchString(w) {
+			b.WriteString("\n" + quote + indent)
+			col = len([]rune(quote + indent))
+			lineStart = true
+		}
+		if !lineStart {
+			b.WriteByte(' ')
+			col++
+		}
+		b.WriteString(w)
+		col += n
+		lineStart = false
+	}
+	return b.String()
+}
+
+// ---------------------------------------------------------------------------
+// Case
+// ---------------------------------------------------------------------------
+
+// transformSelection replaces the selection, on however many lines, with
+// change applied to it, and keeps the result selected.
+func (a *App) transformSelection(change func(string) string, what string) {
+	if a.denyReadOnly() {
+		return
+	}
+	start, end := a.orderedSelection()
+	if start == end {
+		a.status = "Select text to change its case"
+		return
+	}
+	a.unfoldLines(start, end)
+	start, end = a.orderedSelection()
+	text := change(a.editor.SelectedText())
+	a.replaceRange(start, end, text)
+	a.editor.SetCaret(start, start+len([]rune(text)))
+	a.status = "Changed selection to " + what
+}
+
+// titleCase capitalises the first letter of every word in s and lower-cases
+// the rest. Apostrophes do not end a word, so "don't" becomes "Don't".
+func titleCase(s string) string {
+	var b strings.Builder
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if inWord {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToTitle(r)
+			}
+			inWord = true
+		case r == '\'' || r == '’':
+		default:
+			inWord = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ---------------------------------------------------------------------------
+// Navigation
+// ---------------------------------------------------------------------------
+
+// promptGoToLine asks for a 1-based line number and moves the caret there.
+func (a *App) promptGoToLine() {
+	lines := strings.Count(a.docText(), "\n") + 1
+	a.showInputModal("Go to Line", fmt.Sprintf("Line number (1–%d):", lines), func(s string) {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			a.status = "Error: not a line number: " + s
+			return
+		}
+		a.goToLine(n)
+	})
+}
+
+// goToLine places the caret at the start of 1-based line n, clamped to the
+// document. The editor scrolls the caret into view on its next layout.
+func (a *App) goToLine(n int) {
+	a.unfoldAll() // line numbers refer to the full document
+	lines := strings.Split(a.editor.Text(), "\n")
+	n = max(1, min(n, len(lines)))
+	pos := lineColToOffset(lines, n-1, 0)
+	a.editor.SetCaret(pos, pos)
+	a.focusEditor = true
+}
+
+// ---------------------------------------------------------------------------
+// Insertions
+// ---------------------------------------------------------------------------
+
+// insertDateTime replaces the selection with the current time formatted with
+// the configured Go layout string. It does nothing while the editor is
+// read-only, as in vim's normal mode, or in a read-only folder.
+func (a *App) insertDateTime() {
+	if a.denyReadOnly() || a.editor.ReadOnly {
+		return
+	}
+	layout := a.cfg.DateFormat
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	a.editor.Insert(time.Now().Format(layout))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+// ---------------------------------------------------------------------------
+// Emacs keymap (Config.Keymap == "emacs")
+//
+// A handful of emacs bindings layered over the editor: C-a/C-e line motion,
+// M-f/M-b word motion, C-Space to set the mark, C-w/M-w to kill or copy the
+// region, C-k to kill to the end of the line and C-y to yank. Kills go into
+// the keymap register shared with vim mode and onto the system clipboard, so
+// Ctrl+V pastes them too.
+// ---------------------------------------------------------------------------
+
+func (a *App) emacsEnabled() bool {
+	return strings.EqualFold(a.cfg.Keymap, "emacs")
+}
+
+// handleEmacsKeys runs the emacs keymap for this frame, ahead of the editor.
+func (a *App) handleEmacsKeys(gtx layout.Context) {
+	if !a.emacsEnabled() {
+		return
+	}
+	ctrl := func(name key.Name) event.Filter {
+		return key.Filter{Focus: &a.editor, Name: name, Required: key.ModCtrl}
+	}
+	alt := func(name key.Name) event.Filter {
+		return key.Filter{Focus: &a.editor, Name: name, Required: key.ModAlt}
+	}
+	filters := []event.Filter{
+		ctrl("A"), ctrl("E"), ctrl("K"), ctrl("Y"), ctrl("W"), ctrl(key.NameSpace),
+		alt("F"), alt("B"), alt("W"),
+	}
+	for {
+		e, ok := gtx.Event(filters...)
+		if !ok {
+			break
+		}
+		ke, ok := e.(key.Event)
+		if !ok || ke.State != key.Press {
+			continue
+		}
+		a.emacsKey(gtx, ke)
+	}
+
+	// Clicking or typing elsewhere drops the region, as in transient-mark mode.
+	if k := &a.keymap; k.markActive {
+		if _, end := a.editor.Selection(); end != k.mark {
+			k.markActive = false
+		}
+	}
+}
+
+// emacsKey handles one emacs binding.
+func (a *App) emacsKey(gtx layout.Context, ke key.Event) {
+	k := &a.keymap
+	runes := []rune(a.editor.Text())
+	pos, _ := a.editor.Selection() // the caret end of the selection
+	ls, le := lineStart(runes, pos), lineEnd(runes, pos)
+
+	if ke.Modifiers.Contain(key.ModAlt) {
+		switch ke.Name {
+		case "F":
+			a.emacsMove(nextWordEnd(runes, pos))
+		case "B":
+			a.emacsMove(prevWordStart(runes, pos))
+		case "W":
+			if start, end := a.orderedSelection(); start != end {
+				a.emacsKill(gtx, string(runes[start:end]))
+				a.editor.SetCaret(pos, pos)
+			}
+			k.markActive = false
+		}
+		return
+	}
+
+	switch ke.Name {
+	case "K", "W", "Y":
+		if a.denyReadOnly() {
+			return
+		}
+	}
+	switch ke.Name {
+	case "A":
+		a.emacsMove(ls)
+	case "E":
+		a.emacsMove(le)
+	case key.NameSpace:
+		if k.markActive && pos == k.mark {
+			k.markActive = false // C-Space twice: deactivate
+		} else {
+			k.mark, k.markActive = pos, true
+			a.status = "Mark set"
+		}
+		a.editor.SetCaret(pos, pos)
+	case "K":
+		end := le
+		if pos == le && le < len(runes) {
+			end++ // at the end of a line, kill the newline
+		}
+		if end == pos {
+			return
+		}
+		text := string(runes[pos:end])
+		if pos == k.killAt && len(runes) == k.killLen {
+			text = k.register + text
+		}
+		a.emacsKill(gtx, text)
+		a.replaceRange(pos, end, "")
+		k.killAt, k.killLen = pos, len(runes)-(end-pos)
+	case "W":
+		if start, end := a.orderedSelection(); start != end {
+			a.emacsKill(gtx, string(runes[start:end]))
+			a.replaceRange(start, end, "")
+		}
+		k.markActive = false
+	case "Y":
+		if k.register == "" {
+			return
+		}
+		start, end := a.orderedSelection()
+		a.replaceRange(start, end, k.register)
+		k.markActive = false
+	}
+}
+
+// emacsMove moves the caret to pos, extending the region from the mark if it
+// is active.
+func (a *App) emacsMove(pos int) {
+	k := &a.keymap
+	if k.markActive {
+		a.editor.SetCaret(pos, k.mark)
+		return
+	}
+	a.editor.SetCaret(pos, pos)
+}
+
+// emacsKill stores killed or copied text in the register and on the clipboard.
+func (a *App) emacsKill(gtx layout.Context, text string) {
+	a.keymap.register, a.keymap.linewise = text, false
+	writeClipboard(gtx, text)
+}
+
+// nextWordEnd returns the end of the word at or after pos, like emacs
+// forward-word.
+func nextWordEnd(runes []rune, pos int) int {
+	for pos < len(runes) && runeClass(runes[pos]) != 1 {
+		pos++
+	}
+	for pos < len(runes) && runeClass(runes[pos]) == 1 {
+		pos++
+	}
+	return pos
+}
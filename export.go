@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/layout"
+
+	"github.com/ncruces/zenity"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	gmtext "github.com/yuin/goldmark/text"
+)
+
+// htmlStyle is the stylesheet embedded in generated HTML documents.
+const htmlStyle = `body { font-family: sans-serif; line-height: 1.5; max-width: 46em; margin: 2em auto; padding: 0 1em; color: #222; }
+pre, code { font-family: monospace; background: #f4f4f4; }
+pre { padding: 0.6em; overflow-x: auto; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 4px solid #ccc; color: #555; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+img { max-width: 100%; }
+`
+
+// renderHTMLFragment converts markdown to an HTML body fragment with md.
+// Top-level headings get the ids the preview's "#slug" links use. baseDir,
+// if set, is the folder relative links and images resolve from; they are
+// made absolute file:// URLs, so the fragment works wherever it is opened.
+func renderHTMLFragment(md goldmark.Markdown, markdown, baseDir string) ([]byte, error) {
+	src := []byte(markdown)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+	setHeadingIDs(doc, src)
+	if baseDir != "" {
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			switch n := n.(type) {
+			case *ast.Link:
+				if dest, ok := absoluteDest(string(n.Destination), baseDir); ok {
+					n.Destination = []byte(dest)
+				}
+			case *ast.Image:
+				if dest, ok := absoluteDest(string(n.Destination), baseDir); ok {
+					n.Destination = []byte(dest)
+				}
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// absoluteDest resolves a relative link or image destination against dir as
+// a file:// URL. Fragment-only links, absolute URLs and absolute paths are
+// left alone.
+func absoluteDest(dest, dir string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || filepath.IsAbs(filepath.FromSlash(u.Path)) {
+		return "", false
+	}
+	abs, err := url.Parse(fileURL(filepath.Join(dir, filepath.FromSlash(u.Path))))
+	if err != nil {
+		return "", false
+	}
+	abs.RawQuery, abs.Fragment = u.RawQuery, u.Fragment
+	return abs.String(), true
+}
+
+// renderHTMLDocument wraps the rendered markdown in a standalone HTML page.
+// baseDir, if set, is the folder relative links and images resolve from, as
+// for renderHTMLFragment. head is inserted verbatim into <head>.
+func renderHTMLDocument(md goldmark.Markdown, title, markdown, baseDir, head string) ([]byte, error) {
+	body, err := renderHTMLFragment(md, markdown, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return htmlPage(title, body, head), nil
+}
+
+// htmlPage wraps an HTML body fragment in a standalone page, as
+// renderHTMLDocument does.
+func htmlPage(title string, body []byte, head string) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString("<style>\n" + htmlStyle + "</style>\n")
+	b.WriteString(head)
+	b.WriteString("</head>\n<body>\n")
+	b.Write(body)
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+// fileURL returns a file:// URL for an absolute path.
+func fileURL(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive paths
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// printNote renders the current note to a temporary HTML page that opens the
+// print dialog when loaded, and opens it in the default browser. Gio has no
+// printing API, so the browser does the printing.
+func (a *App) printNote() {
+	if a.currentFile == "" {
+		a.status = "Nothing to print: open a file first"
+		return
+	}
+	const autoPrint = "<script>window.addEventListener('load', function () { window.print(); });</script>\n"
+	page, err := renderHTMLDocument(a.md.plain, filepath.Base(a.currentFile), a.docText(), filepath.Dir(a.currentFile), autoPrint)
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+
+	name, err := writePrintPage("note-*.html", page)
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	if err := openWithSystem(name); err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.notify("Opened print preview in browser: " + filepath.Base(a.currentFile))
+}
+
+// printPageAge is how long a print page is kept for the browser to load it.
+const printPageAge = time.Hour
+
+// printDir is the folder print pages are written to.
+func printDir() string {
+	return filepath.Join(os.TempDir(), "marknote-print")
+}
+
+// writePrintPage writes page to a new file in printDir, named after pattern
+// as for os.CreateTemp, and returns its path. Pages left by earlier prints
+// are pruned first.
+func writePrintPage(pattern string, page []byte) (string, error) {
+	prunePrintPages()
+	if err := os.MkdirAll(printDir(), 0700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(printDir(), pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(page)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return f.Name(), err
+}
+
+// prunePrintPages removes the print pages older than printPageAge. Newer
+// ones may still be loading in the browser, possibly for another window or
+// instance.
+func prunePrintPages() {
+	entries, err := os.ReadDir(printDir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > printPageAge {
+			os.Remove(filepath.Join(printDir(), e.Name()))
+		}
+	}
+}
+
+// copyAsHTML renders the selection, or the whole note if nothing is selected,
+// to HTML and copies it. The clipboard only carries plain text, so rich-text
+// apps receive the HTML source.
+func (a *App) copyAsHTML(gtx layout.Context) {
+	src := a.expandFolds(a.editor.SelectedText())
+	what := "selection"
+	if src == "" {
+		src = a.docText()
+		what = "note"
+	}
+	out, err := renderHTMLFragment(a.md.plain, src, "")
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	writeClipboard(gtx, string(out))
+	a.notify("Copied " + what + " as HTML")
+}
+
+// promptExportSelection saves the selection, or the whole note if nothing is
+// selected, to a file picked with the OS save dialog: HTML when its name
+// ends in .html or .htm, markdown otherwise. Relative links and images are
+// rebased onto the file's folder.
+func (a *App) promptExportSelection() {
+	if a.currentFile == "" {
+		a.status = "Nothing to export: open a file first"
+		return
+	}
+	src := a.expandFolds(a.editor.SelectedText())
+	what := "selection"
+	if src == "" {
+		src = a.docText()
+		what = "note"
+	}
+	note, md := a.currentFile, a.md.plain
+	title := strings.TrimSuffix(filepath.Base(note), filepath.Ext(note))
+	name, dialog := title+".html", "Export Note"
+	if what == "selection" {
+		name, dialog = title+"-selection.html", "Export Selection"
+	}
+	go func() {
+		out, err := zenity.SelectFileSave(
+			zenity.Title(dialog),
+			zenity.Filename(filepath.Join(filepath.Dir(note), name)),
+			zenity.FileFilters{
+				{Name: "HTML", Patterns: []string{"*.html", "*.htm"}, CaseFold: true},
+				{Name: "Markdown", Patterns: []string{"*.md"}, CaseFold: true},
+			},
+		)
+		if err != nil || out == "" {
+			return
+		}
+		a.savePickCh <- savePick{targets: []string{out}, write: func() {
+			a.startExport(func() exportResult {
+				err := exportText(md, src, note, title, out)
+				return exportResult{message: "Exported " + what + " to " + filepath.Base(out), err: err}
+			})
+		}}
+		a.window.Invalidate()
+	}()
+}
+
+// exportText writes markdown taken from the note at notePath to out, as an
+// HTML page titled title if out's extension asks for one. md parses it.
+func exportText(md goldmark.Markdown, src, notePath, title, out string) error {
+	data := []byte(rebaseNote(md, src, notePath, filepath.Dir(out), nil))
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".html", ".htm":
+		page, err := renderHTMLDocument(md, title, string(data), "", "")
+		if err != nil {
+			return err
+		}
+		data = page
+	}
+	return os.WriteFile(out, data, 0644)
+}
+
+// copyAsPlainText copies the selection, or the whole note, with markdown
+// formatting stripped.
+func (a *App) copyAsPlainText(gtx layout.Context) {
+	src := a.expandFolds(a.editor.SelectedText())
+	what := "selection"
+	if src == "" {
+		src = a.docText()
+		what = "note"
+	}
+	writeClipboard(gtx, plainText(a.md.plain, src))
+	a.notify("Copied " + what + " as plain text")
+}
+
+// plainText strips markdown formatting from md. Blocks are separated by a
+// blank line, list items and table rows keep a line each, and code blocks
+// keep their content without the fences.
+func plainText(md goldmark.Markdown, text string) string {
+	src := []byte(text)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+	var blocks []string
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if s := plainBlock(n, src, 0); s != "" {
+			blocks = append(blocks, s)
+		}
+	}
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func plainBlock(n ast.Node, src []byte, depth int) string {
+	switch n := n.(type) {
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		return extractCodeLines(n, src)
+	case *ast.ThematicBreak, *ast.HTMLBlock:
+		return ""
+	case *ast.Blockquote:
+		var parts []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if s := plainBlock(c, src, depth); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	case *ast.List:
+		var lines []string
+		counter := n.Start
+		for li := n.FirstChild(); li != nil; li = li.NextSibling() {
+			bullet := "• "
+			if n.IsOrdered() {
+				bullet = fmt.Sprintf("%d. ", counter)
+				counter++
+			}
+			var text, nested []string
+			for c := li.FirstChild(); c != nil; c = c.NextSibling() {
+				if _, ok := c.(*ast.List); ok {
+					nested = append(nested, plainBlock(c, src, depth+1))
+				} else if s := plainBlock(c, src, depth); s != "" {
+					text = append(text, s)
+				}
+			}
+			lines = append(lines, strings.Repeat("  ", depth)+bullet+strings.Join(text, " "))
+			lines = append(lines, nested...)
+		}
+		return strings.Join(lines, "\n")
+	case *extast.Table:
+		var rows []string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, extractText(cell, src))
+			}
+			rows = append(rows, strings.Join(cells, "\t"))
+		}
+		return strings.Join(rows, "\n")
+	}
+	return extractText(n, src)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+
+	errBinaryFile = errors.New("file looks binary (contains NUL bytes)")
+	errLatin1     = errors.New("text contains characters that cannot be saved as Latin-1")
+)
+
+// textEncoding is a character encoding Marknote can read and write.
+type textEncoding int
+
+const (
+	encUTF8 textEncoding = iota
+	encUTF16LE
+	encUTF16BE
+	encLatin1
+	numTextEncodings
+)
+
+func (e textEncoding) label() string {
+	switch e {
+	case encUTF16LE:
+		return "UTF-16 LE"
+	case encUTF16BE:
+		return "UTF-16 BE"
+	case encLatin1:
+		return "Latin-1"
+	default:
+		return "UTF-8"
+	}
+}
+
+// fileFormat records how a file was stored on disk so saveFile can write it
+// back the same way even though the editor always works with UTF-8, LF text.
+type fileFormat struct {
+	encoding textEncoding
+	crlf     bool // lines end in "\r\n"
+	bom      bool // file started with a byte order mark
+	invalid  bool // read as UTF-8 but not valid UTF-8; saving replaces the bad bytes
+}
+
+// lineEnding names the format's line endings for the status bar.
+func (f fileFormat) lineEnding() string {
+	if f.crlf {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// detectEncoding guesses the encoding of data: a BOM wins, then valid UTF-8,
+// with Latin-1 as the fallback. Data without a UTF-16 BOM that contains NUL
+// bytes is rejected as binary.
+func detectEncoding(data []byte) (textEncoding, error) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return encUTF8, nil
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return encUTF16LE, nil
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return encUTF16BE, nil
+	case bytes.IndexByte(data, 0) >= 0:
+		return encUTF8, errBinaryFile
+	case utf8.Valid(data):
+		return encUTF8, nil
+	}
+	return encLatin1, nil
+}
+
+// decodeFile converts raw file bytes into editor text and reports the format
+// they were stored in.
+func decodeFile(data []byte) (string, fileFormat, error) {
+	enc, err := detectEncoding(data)
+	if err != nil {
+		return "", fileFormat{}, err
+	}
+	return decodeAs(data, enc)
+}
+
+// decodeAs converts data from enc into editor text. A matching BOM is stripped
+// so it does not show up in the editor. The line-ending style is taken from
+// the first line break; files without one are treated as LF.
+func decodeAs(data []byte, enc textEncoding) (string, fileFormat, error) {
+	f := fileFormat{encoding: enc}
+	var s string
+	switch enc {
+	case encUTF16LE, encUTF16BE:
+		bom, order := utf16Order(enc)
+		if bytes.HasPrefix(data, bom) {
+			f.bom = true
+			data = data[len(bom):]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		s = string(utf16.Decode(units))
+	case encLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	default:
+		if bytes.IndexByte(data, 0) >= 0 {
+			return "", fileFormat{}, errBinaryFile
+		}
+		if bytes.HasPrefix(data, utf8BOM) {
+			f.bom = true
+			data = data[len(utf8BOM):]
+		}
+		f.invalid = !utf8.Valid(data)
+		s = string(data)
+	}
+
+	if i := strings.IndexByte(s, '\n'); i > 0 && s[i-1] == '\r' {
+		f.crlf = true
+	}
+	return strings.ReplaceAll(s, "\r\n", "\n"), f, nil
+}
+
+// encodeFile converts editor text back into file bytes using f, subject to any
+// line-ending override in the config.
+func (a *App) encodeFile(content string, f fileFormat) ([]byte, error) {
+	crlf := f.crlf
+	switch strings.ToLower(a.cfg.LineEnding) {
+	case "lf":
+		crlf = false
+	case "crlf":
+		crlf = true
+	}
+	if crlf {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+
+	switch f.encoding {
+	case encUTF16LE, encUTF16BE:
+		// The BOM is what identifies these files on the next load, so it is
+		// always written, even if the file was opened without one.
+		bom, order := utf16Order(f.encoding)
+		out := append([]byte{}, bom...)
+		for _, u := range utf16.Encode([]rune(content)) {
+			out = order.AppendUint16(out, u)
+		}
+		return out, nil
+	case encLatin1:
+		out := make([]byte, 0, len(content))
+		for _, r := range content {
+			if r > 0xFF {
+				return nil, errLatin1
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	}
+	if f.bom && a.cfg.PreserveBOM {
+		return append(append([]byte{}, utf8BOM...), content...), nil
+	}
+	return []byte(content), nil
+}
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// utf16Order returns the BOM and byte order for a UTF-16 encoding.
+func utf16Order(enc textEncoding) ([]byte, byteOrder) {
+	if enc == encUTF16BE {
+		return utf16BEBOM, binary.BigEndian
+	}
+	return utf16LEBOM, binary.LittleEndian
+}
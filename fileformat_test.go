@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// A note reopened as UTF-16 from a file without a BOM is saved with one, so
+// the next load detects it instead of rejecting it as binary.
+func TestEncodeUTF16WritesBOM(t *testing.T) {
+	a := &App{}
+	for _, enc := range []textEncoding{encUTF16LE, encUTF16BE} {
+		data, err := a.encodeFile("# Note\n", fileFormat{encoding: enc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := detectEncoding(data)
+		if err != nil || got != enc {
+			t.Errorf("%s: saved file detected as %s, %v", enc.label(), got.label(), err)
+		}
+	}
+}
+
+func TestDecodeInvalidUTF8(t *testing.T) {
+	_, f, err := decodeAs([]byte("caf\xe9\n"), encUTF8)
+	if err != nil || !f.invalid {
+		t.Errorf("invalid UTF-8: invalid = %v, err = %v", f.invalid, err)
+	}
+	_, f, _ = decodeAs([]byte("café\n"), encUTF8)
+	if f.invalid {
+		t.Error("valid UTF-8 marked invalid")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Editor folds
+//
+// widget.Editor cannot hide lines, so folding swaps the folded lines for a
+// single placeholder line in the buffer and keeps the original text aside
+// until another document is opened. docText expands placeholders again
+// wherever the real document is needed (preview, save, export). Moving the
+// caret onto a placeholder unfolds it; deleting the placeholder line deletes
+// the folded text with it. Commands that rewrite whole lines (case, sort,
+// join, rewrap, indent) unfold the lines they touch first.
+// ---------------------------------------------------------------------------
+
+// fold is one collapsed region.
+type fold struct {
+	placeholder string // the line standing in for hidden in the buffer
+	hidden      string // the folded lines, without a trailing newline
+}
+
+// docText returns the document with every fold expanded. Fold records are
+// kept for the life of the document, so a placeholder brought back by undo
+// or redo after its fold was expanded still expands here.
+func (a *App) docText() string {
+	return a.expandFolds(a.editor.Text())
+}
+
+// expandFolds returns text with every fold placeholder in it expanded.
+func (a *App) expandFolds(text string) string {
+	// Later folds may contain earlier placeholders, so expand newest first.
+	for i := len(a.folds) - 1; i >= 0; i-- {
+		f := a.folds[i]
+		text = strings.ReplaceAll(text, f.placeholder, f.hidden)
+	}
+	return text
+}
+
+// unfoldLines expands the folds on the lines from rune offset start to end,
+// keeping the selection on the same lines. Commands that rewrite lines call
+// it first: changing a placeholder's text would stop docText expanding it,
+// and the folded lines would be lost on the next save.
+func (a *App) unfoldLines(start, end int) {
+	if len(a.folds) == 0 {
+		return
+	}
+	runes := []rune(a.editor.Text())
+	ls, le := lineStart(runes, start), lineEnd(runes, end)
+	lines := strings.Split(string(runes[ls:le]), "\n")
+	// Each line's offset from ls and length, before and after.
+	type span struct{ old, oldLen, new, newLen int }
+	spans := make([]span, len(lines))
+	changed := false
+	old, nw := 0, 0
+	for i, l := range lines {
+		full := a.expandFolds(l)
+		changed = changed || full != l
+		spans[i] = span{old, len([]rune(l)), nw, len([]rune(full))}
+		old += spans[i].oldLen + 1
+		nw += spans[i].newLen + 1
+		lines[i] = full
+	}
+	if !changed {
+		return
+	}
+	remap := func(pos int) int {
+		switch {
+		case pos < ls:
+			return pos
+		case pos > le:
+			return pos + nw - old
+		}
+		for _, sp := range spans {
+			if col := pos - ls - sp.old; col <= sp.oldLen {
+				if col == sp.oldLen {
+					col = sp.newLen // the end of an expanded line stays its end
+				}
+				return ls + sp.new + min(col, sp.newLen)
+			}
+		}
+		return pos
+	}
+	selStart, selEnd := a.editor.Selection()
+	a.replaceRange(ls, le, strings.Join(lines, "\n"))
+	a.editor.SetCaret(remap(selStart), remap(selEnd))
+}
+
+// foldAtCaret collapses the fenced code block or heading section containing
+// the caret.
+func (a *App) foldAtCaret() {
+	text := a.editor.Text()
+	lines := strings.Split(text, "\n")
+	start, _ := a.editor.Selection()
+	line, _ := offsetToLineCol(lines, start)
+	from, to, ok := foldRegion(lines, line)
+	if !ok {
+		a.status = "Nothing to fold here"
+		return
+	}
+
+	runes := []rune(text)
+	startOff := lineColToOffset(lines, from, 0)
+	endOff := lineColToOffset(lines, to, len([]rune(lines[to])))
+	a.foldSeq++
+	f := fold{
+		placeholder: fmt.Sprintf("… %d folded lines [%d]", to-from+1, a.foldSeq),
+		hidden:      string(runes[startOff:endOff]),
+	}
+	a.folds = append(a.folds, f)
+	a.replaceRange(startOff, endOff, f.placeholder)
+
+	// Park the caret on the line that owns the fold, not on the placeholder
+	// (which would unfold it straight away).
+	caret := lineColToOffset(lines, from-1, 0)
+	a.editor.SetCaret(caret, caret)
+}
+
+// unfoldAll expands every fold in the buffer.
+func (a *App) unfoldAll() {
+	if len(a.folds) == 0 {
+		return
+	}
+	text := a.editor.Text()
+	if full := a.docText(); full != text {
+		a.setTextKeepCaret(text, full)
+	}
+}
+
+// unfoldAtCaret expands the fold whose placeholder is on the caret line, if
+// any. It is called every frame while folds exist.
+func (a *App) unfoldAtCaret() {
+	if len(a.folds) == 0 {
+		return
+	}
+	runes := []rune(a.editor.Text())
+	start, end := a.editor.Selection()
+	if start != end || start > len(runes) {
+		return
+	}
+	ls, le := lineStart(runes, start), lineEnd(runes, start)
+	lineText := string(runes[ls:le])
+	for _, f := range a.folds {
+		if lineText != f.placeholder {
+			continue
+		}
+		a.replaceRange(ls, le, f.hidden)
+		a.editor.SetCaret(ls, ls)
+		return
+	}
+}
+
+// foldRegion returns the 0-based line range [from, to] that folding at line
+// would hide: the body and closing fence of a fenced code block, or else the
+// body of the enclosing heading section up to the next heading of the same or
+// higher level (trailing blank lines stay visible).
+func foldRegion(lines []string, line int) (from, to int, ok bool) {
+	type heading struct{ line, level int }
+	var headings []heading
+	fenceOpen := -1
+	var fence string
+	for i, l := range lines {
+		t := strings.TrimLeft(l, " ")
+		if len(l)-len(t) > 3 {
+			continue
+		}
+		if fenceOpen >= 0 {
+			if strings.HasPrefix(t, fence) && strings.TrimSpace(strings.TrimLeft(t, fence[:1])) == "" {
+				if line >= fenceOpen && line <= i && i > fenceOpen+1 {
+					return fenceOpen + 1, i, true
+				}
+				fenceOpen = -1
+			}
+			continue
+		}
+		if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			fenceOpen, fence = i, t[:3]
+			continue
+		}
+		if lvl := headingLevel(t); lvl > 0 {
+			headings = append(headings, heading{i, lvl})
+		}
+	}
+	if fenceOpen >= 0 && line >= fenceOpen && fenceOpen+1 < len(lines) {
+		// Unclosed fence: runs to the end of the document.
+		return fenceOpen + 1, len(lines) - 1, true
+	}
+
+	owner := -1
+	for i, h := range headings {
+		if h.line <= line {
+			owner = i
+		}
+	}
+	if owner < 0 {
+		return 0, 0, false
+	}
+	h := headings[owner]
+	end := len(lines) - 1
+	for _, next := range headings[owner+1:] {
+		if next.level <= h.level {
+			end = next.line - 1
+			break
+		}
+	}
+	for end > h.line && strings.TrimSpace(lines[end]) == "" {
+		end--
+	}
+	if end <= h.line {
+		return 0, 0, false
+	}
+	return h.line + 1, end, true
+}
+
+// headingLevel returns the ATX heading level of line, or 0.
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '#' {
+		n++
+	}
+	if n == 0 || n > 6 || (n < len(line) && line[n] != ' ' && line[n] != '\t') {
+		return 0
+	}
+	return n
+}
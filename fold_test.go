@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Undoing an unfold brings the placeholder back into the buffer; docText
+// must still expand it so saving does not lose the folded lines.
+func TestDocTextAfterUnfoldUndo(t *testing.T) {
+	const doc = "# Title\nfirst\nsecond\n# Next\nmore"
+	a := &App{}
+	a.editor.SetText(doc)
+	a.editor.SetCaret(3, 3)
+	a.foldAtCaret()
+	folded := a.editor.Text()
+	if folded == doc {
+		t.Fatal("nothing was folded")
+	}
+
+	a.unfoldAll()
+	if got := a.editor.Text(); got != doc {
+		t.Fatalf("unfolded buffer = %q, want %q", got, doc)
+	}
+	a.editor.SetText(folded) // what Ctrl+Z restores
+	if got := a.docText(); got != doc {
+		t.Errorf("docText after undo = %q, want %q", got, doc)
+	}
+
+	// Unfolding by moving onto the placeholder, then undoing, likewise.
+	a.editor.SetText(folded)
+	a.editor.SetCaret(len("# Title\n"), len("# Title\n"))
+	a.unfoldAtCaret()
+	if got := a.editor.Text(); got != doc {
+		t.Fatalf("buffer after unfold at caret = %q, want %q", got, doc)
+	}
+	a.editor.SetText(folded)
+	if got := a.docText(); got != doc {
+		t.Errorf("docText after unfold at caret and undo = %q, want %q", got, doc)
+	}
+}
+
+// Line commands over a fold work on the folded lines, not the placeholder,
+// so the document keeps them.
+func TestLineCommandsUnfold(t *testing.T) {
+	const doc = "# Title\nfirst line\nsecond line\n# Next\nmore"
+	commands := map[string]struct {
+		run  func(a *App)
+		want string
+	}{
+		"upper case": {func(a *App) { a.transformSelection(strings.ToUpper, "upper case") },
+			"# TITLE\nFIRST LINE\nSECOND LINE\n# NEXT\nMORE"},
+		"sort": {func(a *App) { a.sortLines(false, false) },
+			"# Next\n# Title\nfirst line\nmore\nsecond line"},
+		"join": {func(a *App) { a.joinLines() },
+			"# Title first line second line # Next more"},
+	}
+	for name, c := range commands {
+		a := &App{}
+		a.editor.SetText(doc)
+		a.editor.SetCaret(3, 3)
+		a.foldAtCaret()
+		a.editor.SetCaret(0, a.editor.Len())
+		c.run(a)
+		if got := a.docText(); got != c.want {
+			t.Errorf("%s: docText = %q, want %q", name, got, c.want)
+		}
+	}
+}
+
+// Rewrapping the text after a folded code block leaves the block alone.
+func TestReflowUnfolds(t *testing.T) {
+	const doc = "```\ncode\n```\nsome\ntext"
+	a := &App{}
+	a.editor.SetText(doc)
+	a.editor.SetCaret(0, 0)
+	a.foldAtCaret()
+	end := a.editor.Len()
+	a.editor.SetCaret(end, end)
+	a.reflowParagraph(true)
+	if got, want := a.docText(), "```\ncode\n```\nsome text"; got != want {
+		t.Errorf("docText = %q, want %q", got, want)
+	}
+}
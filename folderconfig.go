@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Folder settings
+//
+// A .marknote.json in the open folder overrides a few settings for that
+// folder alone: the theme, markdown flavor, autosave and ignore patterns,
+// written with the same keys and values as in the config file. The global
+// values they replace are kept in App.folderGlobals, saved in their place
+// whenever the config is written, and put back when another folder opens.
+// Changing an overridden setting from the UI lasts until then.
+// ---------------------------------------------------------------------------
+
+const folderConfigName = ".marknote.json"
+
+// folderConfigKeys are the config keys a folder may override.
+var folderConfigKeys = []string{
+	"theme", "markdownFlavor",
+	"saveOnFocusLoss", "gitAutosave", "gitAutosaveInterval",
+	"ignorePatterns",
+}
+
+// loadFolderConfig puts back the settings the previous folder overrode,
+// then applies root's overrides, if it has any. A file that does not parse
+// is reported and left out whole; unknown keys are reported and skipped.
+func (a *App) loadFolderConfig(root string) {
+	prev := a.cfg
+	if err := overlayConfig(&a.cfg, a.folderGlobals); err != nil {
+		a.notifyError(err)
+	}
+	a.folderGlobals = nil
+	defer a.applyFolderConfig(prev)
+
+	data, err := os.ReadFile(filepath.Join(root, folderConfigName))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			a.notifyError(err)
+		}
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		a.notifyError(fmt.Errorf("%s: %w", folderConfigName, err))
+		return
+	}
+	var unknown []string
+	for k := range fields {
+		if !slices.Contains(folderConfigKeys, k) {
+			unknown = append(unknown, k)
+			delete(fields, k)
+		}
+	}
+	// Overlay a copy, so a value of the wrong type leaves the config alone.
+	cfg := a.cfg
+	if err := overlayConfig(&cfg, fields); err != nil {
+		a.notifyError(fmt.Errorf("%s: %w", folderConfigName, err))
+		return
+	}
+	var global map[string]json.RawMessage
+	if data, err = json.Marshal(a.cfg); err == nil {
+		err = json.Unmarshal(data, &global)
+	}
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.folderGlobals = map[string]json.RawMessage{}
+	for k := range fields {
+		a.folderGlobals[k] = global[k]
+	}
+	a.cfg = cfg
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		a.notifyError(fmt.Errorf("%s: unknown or global-only settings ignored: %s", folderConfigName, strings.Join(unknown, ", ")))
+	}
+}
+
+// applyFolderConfig puts into effect the settings that changed from prev
+// and are not read afresh where they are used. The ignore patterns are
+// loaded with the folder.
+func (a *App) applyFolderConfig(prev Config) {
+	if a.cfg.Theme != prev.Theme {
+		a.applyTheme(themeNamed(a.cfg.Theme))
+	}
+	if a.cfg.MarkdownFlavor != prev.MarkdownFlavor {
+		a.md = newMarkdownParsers(a.cfg.MarkdownFlavor)
+	}
+}
+
+// overlayConfig sets the fields of cfg named by the keys of fields to their
+// JSON values, leaving the rest alone.
+func overlayConfig(cfg *Config, fields map[string]json.RawMessage) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	// Decoding reuses a slice's backing array, which cfg may share with
+	// the config it was copied from.
+	cfg.IgnorePatterns = slices.Clone(cfg.IgnorePatterns)
+	return json.Unmarshal(data, cfg)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/font/gofont"
+	"gioui.org/font/opentype"
+	"gioui.org/text"
+)
+
+// ---------------------------------------------------------------------------
+// Fonts
+//
+// Config.UIFont, EditorFont and MonoFont are family lists in Gio's Typeface
+// syntax, tried in order against gofont, the font files in Config.FontDir
+// and the system's installed fonts. gofont's Go or Go Mono is always
+// appended, so an unknown family still renders. Fonts are shaped with their
+// ligatures, so a coding font's arrows and operators join up in code.
+// ---------------------------------------------------------------------------
+
+const (
+	defaultFace = "Go"
+	monoFace    = "Go Mono"
+)
+
+// typeface returns families followed by fallback.
+func typeface(families, fallback string) font.Typeface {
+	if families = strings.TrimSpace(families); families == "" {
+		return font.Typeface(fallback)
+	}
+	return font.Typeface(families + ", " + fallback)
+}
+
+// loadFonts builds the theme's shaper and default face from the config.
+// Font files that cannot be read are reported and skipped.
+func (a *App) loadFonts() {
+	collection := gofont.Collection()
+	if a.cfg.FontDir != "" {
+		faces, err := loadFontDir(a.cfg.FontDir)
+		if err != nil {
+			a.notifyError(err)
+		}
+		collection = append(collection, faces...)
+	}
+	a.th.Shaper = text.NewShaper(text.WithCollection(collection))
+	a.th.Face = typeface(a.cfg.UIFont, defaultFace)
+}
+
+// loadFontDir parses every TrueType and OpenType file in dir and its
+// subfolders, including .ttc/.otc collections. The faces that parsed are
+// returned alongside an error naming the files that did not.
+func loadFontDir(dir string) ([]font.FontFace, error) {
+	var faces []font.FontFace
+	var failed []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".ttf", ".otf", ".ttc", ".otc":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err == nil {
+			var ff []font.FontFace
+			if ff, err = opentype.ParseCollection(data); err == nil {
+				faces = append(faces, ff...)
+				return nil
+			}
+		}
+		failed = append(failed, filepath.Base(p))
+		return nil
+	})
+	if err != nil {
+		return faces, fmt.Errorf("font folder: %w", err)
+	}
+	if len(failed) > 0 {
+		return faces, errors.New("could not load fonts: " + strings.Join(failed, ", "))
+	}
+	return faces, nil
+}
+
+// editorTypeface is the editor's face: EditorFont, else the UI font.
+func (a *App) editorTypeface() font.Typeface {
+	if strings.TrimSpace(a.cfg.EditorFont) == "" {
+		return a.th.Face
+	}
+	return typeface(a.cfg.EditorFont, defaultFace)
+}
+
+// monoTypeface is the face of code blocks and inline code.
+func (a *App) monoTypeface() font.Typeface {
+	return typeface(a.cfg.MonoFont, monoFace)
+}
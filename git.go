@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// ---------------------------------------------------------------------------
+// Git status markers
+//
+// When the open folder is inside a git work tree, `git status --porcelain`
+// runs in the background after each save, when a folder is opened, and every
+// gitStatusInterval. Tree rows get a coloured dot for modified, staged or
+// untracked files; folders show the most notable status beneath them.
+// ---------------------------------------------------------------------------
+
+const gitStatusInterval = 10 * time.Second
+
+// gitState is a file's status, ordered so that larger values win when
+// summarising a folder.
+type gitState uint8
+
+const (
+	gitClean gitState = iota
+	gitStaged
+	gitUntracked
+	gitModified
+)
+
+func (s gitState) color() color.NRGBA {
+	switch s {
+	case gitStaged:
+		return color.NRGBA{R: 0x2E, G: 0xA0, B: 0x43, A: 0xFF}
+	case gitUntracked:
+		return color.NRGBA{R: 0x3A, G: 0x8E, B: 0xE6, A: 0xFF}
+	case gitModified:
+		return color.NRGBA{R: 0xE0, G: 0x9B, B: 0x1A, A: 0xFF}
+	}
+	return color.NRGBA{}
+}
+
+// gitStatusResult carries one `git status` run back to the frame loop.
+type gitStatusResult struct {
+	root   string
+	states map[string]gitState // by absolute path, folders included
+}
+
+// refreshGitStatus starts a background `git status` for the open folder's
+// work tree, unless one is already running.
+func (a *App) refreshGitStatus() {
+	if !a.cfg.ShowGitStatus || a.gitRoot == "" || a.gitPending {
+		return
+	}
+	a.gitPending = true
+	a.gitStatusAt = time.Now()
+	root := a.gitRoot
+	go func() {
+		out, err := exec.Command("git", "-C", root, "status", "--porcelain=v1", "-z", "--untracked-files=all").Output()
+		res := gitStatusResult{root: root}
+		if err == nil {
+			res.states = parseGitStatus(root, out)
+		}
+		a.gitStatusCh <- res
+		a.window.Invalidate()
+	}()
+}
+
+// pollGitStatus refreshes the markers every gitStatusInterval.
+func (a *App) pollGitStatus(gtx layout.Context) {
+	if !a.cfg.ShowGitStatus || a.gitRoot == "" {
+		return
+	}
+	if next := a.gitStatusAt.Add(gitStatusInterval); gtx.Now.Before(next) {
+		gtx.Execute(op.InvalidateCmd{At: next})
+		return
+	}
+	a.refreshGitStatus()
+}
+
+// applyGitStatus stores a finished run, if it is still for the open folder.
+func (a *App) applyGitStatus(res gitStatusResult) {
+	a.gitPending = false
+	if res.root == a.gitRoot {
+		a.gitStates = res.states
+	}
+}
+
+// parseGitStatus turns `git status --porcelain=v1 -z` output into states by
+// absolute path, marking every folder up to root with its most notable
+// descendant.
+func parseGitStatus(root string, out []byte) map[string]gitState {
+	states := make(map[string]gitState)
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if len(f) < 4 {
+			continue
+		}
+		x, y := f[0], f[1]
+		if x == 'R' || x == 'C' {
+			i++ // the next field is the original path
+		}
+		var st gitState
+		switch {
+		case x == '?' && y == '?':
+			st = gitUntracked
+		case y != ' ':
+			st = gitModified
+		case x != ' ':
+			st = gitStaged
+		default:
+			continue
+		}
+		p := filepath.Join(root, filepath.FromSlash(string(f[3:])))
+		for ; ; p = filepath.Dir(p) {
+			if states[p] < st {
+				states[p] = st
+			}
+			if p == root || p == filepath.Dir(p) {
+				break
+			}
+		}
+	}
+	return states
+}
+
+// layoutGitMarker draws the status dot for a tree row, or nothing.
+func layoutGitMarker(gtx layout.Context, st gitState) layout.Dimensions {
+	if st == gitClean {
+		return layout.Dimensions{}
+	}
+	d := gtx.Dp(7)
+	size := image.Pt(d+gtx.Dp(8), d)
+	off := op.Offset(image.Pt(gtx.Dp(4), 0)).Push(gtx.Ops)
+	paint.FillShape(gtx.Ops, st.color(), clip.Ellipse{Max: image.Pt(d, d)}.Op(gtx.Ops))
+	off.Pop()
+	return layout.Dimensions{Size: size}
+}
+
+// ---------------------------------------------------------------------------
+// Committing
+// ---------------------------------------------------------------------------
+
+// gitCommitResult carries one commit attempt back to the frame loop.
+type gitCommitResult struct {
+	message string
+	err     error
+}
+
+// promptGitCommit asks for a commit message and commits the current note, or
+// every change in the work tree. It does nothing outside a git work tree.
+func (a *App) promptGitCommit() {
+	if a.denyReadOnly() {
+		return
+	}
+	if a.gitRoot == "" {
+		a.status = "Not a git repository"
+		return
+	}
+	m := a.showInputModal("Commit", "Commit message:", nil)
+	m.okLabel = "Commit"
+	if a.currentFile != "" {
+		m.options = []string{"This note", "All changes"}
+	}
+	m.onOK = func(message string) {
+		message = strings.TrimSpace(message)
+		if message == "" {
+			a.notifyError(errors.New("commit message is empty"))
+			return
+		}
+		if a.modified {
+			// Skip GitAutoCommit, or its commit would leave this one empty.
+			a.saveNote(false)
+			if a.modified {
+				return
+			}
+		}
+		var file string
+		if len(m.options) > 0 && m.option == 0 {
+			file = a.currentFile
+		}
+		a.gitCommit(file, message)
+	}
+}
+
+// gitCommit stages and commits file, or all changes when file is "", in the
+// background.
+func (a *App) gitCommit(file, message string) {
+	root := a.gitRoot
+	a.tasks++
+	go func() {
+		a.gitCommitCh <- gitCommitResult{message: message, err: runGitCommit(root, file, message)}
+		a.window.Invalidate()
+	}()
+}
+
+// gitCommitMu serialises commits so quick successive saves with
+// GitAutoCommit do not race for the index lock.
+var gitCommitMu sync.Mutex
+
+// runGitCommit runs git add and git commit in root.
+func runGitCommit(root, file, message string) error {
+	gitCommitMu.Lock()
+	defer gitCommitMu.Unlock()
+	git := func(args ...string) error {
+		out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput()
+		if err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return fmt.Errorf("git %s: %s", args[0], msg)
+			}
+			return fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return nil
+	}
+	if file == "" {
+		if err := git("add", "-A"); err != nil {
+			return err
+		}
+		return git("commit", "-m", message)
+	}
+	if err := git("add", "--", file); err != nil {
+		return err
+	}
+	return git("commit", "-m", message, "--", file)
+}
+
+// applyGitCommit reports a finished commit and refreshes the markers.
+func (a *App) applyGitCommit(res gitCommitResult) {
+	a.tasks--
+	if res.err != nil {
+		a.notifyError(res.err)
+		return
+	}
+	a.notify("Committed: " + res.message)
+	a.refreshGitStatus()
+}
+
+// ---------------------------------------------------------------------------
+// Autosave branch
+//
+// With Config.GitAutosave, the open note's text, saved or not, is committed
+// every GitAutosaveInterval seconds while it differs from the last snapshot,
+// to gitAutosaveBranch. The commits are built with git plumbing against a
+// temporary index, so the work tree, the real index and the checked-out
+// branch are never touched. The first snapshot branches off HEAD.
+// ---------------------------------------------------------------------------
+
+const gitAutosaveBranch = "marknote-autosave"
+
+// gitAutosaveResult carries one snapshot back to the frame loop.
+type gitAutosaveResult struct {
+	file, text string
+	err        error
+}
+
+// pollGitAutosave starts a snapshot when one is due and none is running.
+func (a *App) pollGitAutosave(gtx layout.Context) {
+	interval := time.Duration(a.cfg.GitAutosaveInterval) * time.Second
+	if !a.cfg.GitAutosave || interval <= 0 || a.gitRoot == "" || a.currentFile == "" || a.autosavePending {
+		return
+	}
+	if next := a.autosaveAt.Add(interval); gtx.Now.Before(next) {
+		gtx.Execute(op.InvalidateCmd{At: next})
+		return
+	}
+	a.autosaveAt = gtx.Now
+	text := a.docText()
+	if a.autosaveFile == a.currentFile && text == a.autosaveText {
+		return
+	}
+	root, file := a.gitRoot, a.currentFile
+	a.autosavePending = true
+	go func() {
+		a.gitAutosaveCh <- gitAutosaveResult{file: file, text: text, err: runGitAutosave(root, file, text)}
+		a.window.Invalidate()
+	}()
+}
+
+// applyGitAutosave records a finished snapshot. Failures only show in the
+// status bar; the next interval tries again.
+func (a *App) applyGitAutosave(res gitAutosaveResult) {
+	a.autosavePending = false
+	if res.err != nil {
+		a.status = "Git autosave failed: " + res.err.Error()
+		return
+	}
+	a.autosaveFile, a.autosaveText = res.file, res.text
+}
+
+// runGitAutosave commits text as file's content on top of the autosave
+// branch.
+func runGitAutosave(root, file, text string) error {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return err
+	}
+	// git treats an empty index file as corrupt, so the index must not exist
+	// until git writes it.
+	dir, err := os.MkdirTemp("", "marknote-autosave-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	index := filepath.Join(dir, "index")
+
+	git := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+		cmd.Stdin = strings.NewReader(stdin)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("git %s: %s", args[0], msg)
+			}
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	parent, err := git("", "rev-parse", "--verify", "--quiet", "refs/heads/"+gitAutosaveBranch)
+	if err != nil {
+		parent, _ = git("", "rev-parse", "--verify", "--quiet", "HEAD")
+	}
+	if parent != "" {
+		if _, err := git("", "read-tree", parent); err != nil {
+			return err
+		}
+	}
+	blob, err := git(text, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	if _, err := git("", "update-index", "--add", "--cacheinfo", "100644,"+blob+","+filepath.ToSlash(rel)); err != nil {
+		return err
+	}
+	tree, err := git("", "write-tree")
+	if err != nil {
+		return err
+	}
+	args := []string{"commit-tree", tree, "-m", "Autosave " + filepath.ToSlash(rel)}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := git("", args...)
+	if err != nil {
+		return err
+	}
+	_, err = git("", "update-ref", "refs/heads/"+gitAutosaveBranch, commit)
+	return err
+}
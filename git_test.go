@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A repository with no commits yet has nothing to read into the autosave
+// index, so the snapshot starts from an index git creates itself.
+func TestGitAutosaveNoCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	for _, kv := range [][2]string{
+		{"GIT_AUTHOR_NAME", "Test"}, {"GIT_AUTHOR_EMAIL", "test@example.com"},
+		{"GIT_COMMITTER_NAME", "Test"}, {"GIT_COMMITTER_EMAIL", "test@example.com"},
+	} {
+		t.Setenv(kv[0], kv[1])
+	}
+	file := filepath.Join(root, "notes", "a.md")
+
+	for _, text := range []string{"first\n", "second\n"} {
+		if err := runGitAutosave(root, file, text); err != nil {
+			t.Fatalf("autosave %q: %v", text, err)
+		}
+		out, err := exec.Command("git", "-C", root, "show", gitAutosaveBranch+":notes/a.md").Output()
+		if err != nil {
+			t.Fatalf("git show: %v", err)
+		}
+		if string(out) != text {
+			t.Errorf("autosaved %q, want %q", out, text)
+		}
+	}
+	out, err := exec.Command("git", "-C", root, "rev-list", "--count", gitAutosaveBranch).Output()
+	if err != nil {
+		t.Fatalf("git rev-list: %v", err)
+	}
+	if n := strings.TrimSpace(string(out)); n != "2" {
+		t.Errorf("autosave branch has %s commits, want 2", n)
+	}
+	if _, err := os.Stat(filepath.Join(root, "notes")); !os.IsNotExist(err) {
+		t.Errorf("autosave touched the work tree")
+	}
+}
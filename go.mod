@@ -6,6 +6,7 @@ require (
 	gioui.org v0.9.0
 	github.com/ncruces/zenity v0.10.14
 	github.com/yuin/goldmark v1.7.8
+	golang.org/x/net v0.38.0
 )
 
 require (
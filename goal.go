@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Word goal
+//
+// With Config.WordGoal set, the status bar shows the open note's word count
+// against it beside a small progress bar, and a toast marks the edit that
+// reaches it. Ctrl+Shift+W changes the goal.
+// ---------------------------------------------------------------------------
+
+// promptWordGoal asks for a new word goal; 0 or empty turns it off.
+func (a *App) promptWordGoal() {
+	m := a.showInputModal("Word Goal", "Words to aim for in each note (0 for none):", func(s string) {
+		s = strings.TrimSpace(s)
+		n := 0
+		if s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil || n < 0 {
+				a.status = "Error: not a word count: " + s
+				return
+			}
+		}
+		a.cfg.WordGoal = n
+		a.saveConfig()
+		a.goalMet = a.words >= n
+	})
+	if a.cfg.WordGoal > 0 {
+		m.input.SetText(strconv.Itoa(a.cfg.WordGoal))
+	}
+}
+
+// countWords updates the open note's word count from its text, celebrating
+// when an edit first takes it to the goal. A note opened already past the
+// goal is not celebrated.
+func (a *App) countWords(content string) {
+	a.words = len(strings.Fields(content))
+	goal := a.cfg.WordGoal
+	if a.wordsFile != a.currentFile {
+		a.wordsFile = a.currentFile
+		a.goalMet = a.words >= goal
+		return
+	}
+	if goal > 0 && !a.goalMet && a.words >= goal {
+		a.goalMet = true
+		a.notify(fmt.Sprintf("Word goal reached: %d words", goal))
+	}
+}
+
+// layoutWordGoal draws "words / goal" and a progress bar in the status bar.
+func (a *App) layoutWordGoal(gtx layout.Context) layout.Dimensions {
+	goal := a.cfg.WordGoal
+	if goal <= 0 || a.currentFile == "" {
+		return layout.Dimensions{}
+	}
+	return layout.Inset{Right: unit.Dp(16)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(material.Label(a.th, unit.Sp(12), fmt.Sprintf("%d / %d words", a.words, goal)).Layout),
+			layout.Rigid(spacer(6)),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				size := image.Pt(gtx.Dp(48), gtx.Dp(4))
+				rr := gtx.Dp(2)
+				paint.FillShape(gtx.Ops, mulAlpha(a.th.Palette.Fg, 40), clip.UniformRRect(image.Rectangle{Max: size}, rr).Op(gtx.Ops))
+				done := size.X * min(a.words, goal) / goal
+				paint.FillShape(gtx.Ops, a.th.Palette.ContrastBg, clip.UniformRRect(image.Rect(0, 0, done, size.Y), rr).Op(gtx.Ops))
+				return layout.Dimensions{Size: size}
+			}),
+		)
+	})
+}
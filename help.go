@@ -0,0 +1,146 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// cheatSheet is the markdown shown by the help overlay. It is rendered through
+// the normal preview path, so it doubles as a sample of every supported block.
+const cheatSheet = "# Markdown Cheat Sheet\n\n" +
+	"## Text\n\n" +
+	"**Bold** with `**bold**`, *italic* with `*italic*`, ~~strike~~ with `~~strike~~`, " +
+	"and `inline code` with backticks. Links are written `[text](https://example.com)`.\n\n" +
+	"==Highlight== with `==highlight==`, x^2^ with `x^2^` and H~2~O with `H~2~O`.\n\n" +
+	"## Headings\n\n" +
+	"Start a line with one to six `#` characters: `# Title`, `## Section`, `### Subsection`.\n\n" +
+	"## Lists\n\n" +
+	"- Bullets start with `-`, `*` or `+`\n" +
+	"- Indent by two spaces to nest\n" +
+	"  - like this\n\n" +
+	"1. Numbered items start with `1.`\n" +
+	"2. The numbers need not be in order\n\n" +
+	"## Quotes\n\n" +
+	"> Start a line with `>` to quote it.\n\n" +
+	"## Code blocks\n\n" +
+	"~~~\n" +
+	"Fence code with three backticks or tildes:\n" +
+	"func main() {}\n" +
+	"~~~\n\n" +
+	"## Tables\n\n" +
+	"| Column | Column |\n" +
+	"|--------|--------|\n" +
+	"| Cells are separated | by pipes |\n" +
+	"| A row of dashes | ends the header |\n\n" +
+	"## Rules\n\n" +
+	"Three dashes on their own line draw a horizontal rule:\n\n" +
+	"---\n\n" +
+	"## Shortcuts\n\n" +
+	"| Keys | Action |\n" +
+	"|------|--------|\n" +
+	"| Ctrl+N | New file |\n" +
+	"| Ctrl+Shift+N | New window |\n" +
+	"| Ctrl+O | Open folder |\n" +
+	"| Ctrl+Shift+O | Open folder read-only |\n" +
+	"| Ctrl+S | Save |\n" +
+	"| Ctrl+Shift+S | Save as |\n" +
+	"| Ctrl+Tab | Switch to the previous file |\n" +
+	"| Ctrl+Shift+L | Reveal the open file in the tree |\n" +
+	"| Ctrl+R | Reload from disk |\n" +
+	"| Ctrl+G | Go to line |\n" +
+	"| Ctrl+Home / Ctrl+End | Top / bottom of the editor or preview |\n" +
+	"| Ctrl+[ / Ctrl+] | Fold section or code block / unfold all |\n" +
+	"| Ctrl+J | Today's journal note |\n" +
+	"| Ctrl+P | Print |\n" +
+	"| Ctrl+Shift+C | Copy as HTML |\n" +
+	"| Ctrl+Shift+X | Export the selection or note to an HTML or markdown file |\n" +
+	"| Ctrl+Alt+C | Copy as plain text |\n" +
+	"| Ctrl+Shift+V | Paste HTML as markdown |\n" +
+	"| Ctrl+Shift+R | Refresh preview |\n" +
+	"| Ctrl+Click | Follow a preview link to another note |\n" +
+	"| Ctrl+Shift+I | Document statistics |\n" +
+	"| Ctrl+Shift+G | Commit to git |\n" +
+	"| Ctrl+Shift+D | Define the word at the caret |\n" +
+	"| Ctrl+Shift+T | Insert or update a table of contents |\n" +
+	"| Alt+Up / Alt+Down | Move the line or selected lines |\n" +
+	"| Ctrl+D | Duplicate the line or selected lines |\n" +
+	"| Ctrl+Shift+J | Join the selected lines, or the line with the next |\n" +
+	"| Alt+Q / Alt+Shift+Q | Rewrap the paragraph to the wrap column, or unwrap it |\n" +
+	"| Alt+Click | Add a caret |\n" +
+	"| Alt+Shift+I | Put a caret on each selected line |\n" +
+	"| Ctrl+Alt+U / L / T | Change the selection to UPPERCASE, lowercase or Title Case |\n" +
+	"| Ctrl+Alt+S | Sort the selected lines |\n" +
+	"| Ctrl+Shift+E | Recent files |\n" +
+	"| Ctrl+Shift+H | Export the folder as an HTML site |\n" +
+	"| Ctrl+Shift+M | Combine notes into one document |\n" +
+	"| Ctrl+Shift+W | Set a word goal |\n" +
+	"| Ctrl+Alt+V | Paste clipboard image |\n" +
+	"| Tab / Shift+Tab | Indent / outdent lines |\n" +
+	"| F1 | Toggle this help |\n" +
+	"| F5 | Insert date/time |\n" +
+	"| F7 | Swap editor and preview |\n" +
+	"| F8 | Stack editor and preview |\n" +
+	"| F11 | Focus mode |\n"
+
+// toggleHelp shows or hides the cheat-sheet overlay.
+func (a *App) toggleHelp() {
+	a.showHelp = !a.showHelp
+	if a.showHelp && a.helpBlocks == nil {
+		a.helpBlocks = renderMarkdown(cheatSheet, a.renderOptions())
+		a.helpList.Axis = layout.Vertical
+	}
+	a.window.Invalidate()
+}
+
+// layoutHelp draws the cheat sheet in a centered card over a scrim. Clicking
+// the scrim closes it.
+func (a *App) layoutHelp(gtx layout.Context) layout.Dimensions {
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &a.showHelp, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if _, ok := e.(pointer.Event); ok {
+			a.showHelp = false
+			return layout.Dimensions{}
+		}
+	}
+	for {
+		if _, ok := gtx.Event(pointer.Filter{Target: &a.helpBlocks, Kinds: pointer.Press}); !ok {
+			break
+		}
+	}
+
+	paint.FillShape(gtx.Ops, color.NRGBA{A: 150}, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	scrim := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &a.showHelp)
+	scrim.Pop()
+
+	st := a.previewStyle()
+	return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		size := image.Pt(min(gtx.Dp(640), gtx.Constraints.Max.X-gtx.Dp(40)), gtx.Constraints.Max.Y-gtx.Dp(80))
+		gtx.Constraints = layout.Exact(size)
+		defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+		// Swallow clicks on the card so they do not reach the scrim.
+		event.Op(gtx.Ops, &a.helpBlocks)
+		paint.FillShape(gtx.Ops, previewBg(a.th.Palette.Bg), clip.Rect{Max: size}.Op())
+
+		return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return material.List(a.th, &a.helpList).Layout(gtx, len(a.helpBlocks),
+				func(gtx layout.Context, i int) layout.Dimensions {
+					return layout.Inset{Bottom: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return a.helpBlocks[i].Layout(gtx, a.th, st)
+					})
+				},
+			)
+		})
+	})
+}
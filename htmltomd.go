@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlTagRe matches common block and inline tags; clipboard text containing
+// one is treated as HTML by looksLikeHTML.
+var htmlTagRe = regexp.MustCompile(`(?i)<(p|div|span|a|b|strong|em|i|h[1-6]|ul|ol|li|br|img|table|pre|code|blockquote|html|body)[\s/>]`)
+
+// looksLikeHTML reports whether s appears to be an HTML fragment.
+func looksLikeHTML(s string) bool {
+	return htmlTagRe.MatchString(s)
+}
+
+// htmlToMarkdown converts an HTML fragment to markdown. It covers headings,
+// paragraphs, emphasis, links, images, lists, code, blockquotes and rules;
+// other elements contribute only their text.
+func htmlToMarkdown(src string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return "", err
+	}
+	c := &mdConverter{}
+	for _, n := range nodes {
+		c.node(n)
+	}
+	lines := strings.Split(c.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	out := blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(out) + "\n", nil
+}
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+type mdConverter struct {
+	b         strings.Builder
+	listStack []int // per nested list: next ordinal, or -1 for bullets
+	inPre     bool
+	inCode    bool
+}
+
+// atSpace reports whether the output is empty or ends in whitespace, so a
+// leading space in the next text run would be redundant.
+func (c *mdConverter) atSpace() bool {
+	s := c.b.String()
+	return s == "" || strings.HasSuffix(s, " ") || strings.HasSuffix(s, "\n")
+}
+
+// block ensures the output is separated from the next block by a blank line.
+func (c *mdConverter) block() {
+	c.b.WriteString("\n\n")
+}
+
+func (c *mdConverter) children(n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.node(ch)
+	}
+}
+
+func (c *mdConverter) wrap(n *html.Node, marker string) {
+	c.b.WriteString(marker)
+	c.children(n)
+	c.b.WriteString(marker)
+}
+
+func (c *mdConverter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if c.inPre {
+			c.b.WriteString(n.Data)
+			return
+		}
+		t := collapseSpace(n.Data)
+		if c.atSpace() {
+			t = strings.TrimLeft(t, " ")
+		}
+		if !c.inCode {
+			t = mdEscaper.Replace(t)
+		}
+		c.b.WriteString(t)
+		return
+	case html.ElementNode:
+	default:
+		c.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.block()
+		c.b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		c.children(n)
+		c.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Table, atom.Tr:
+		c.block()
+		c.children(n)
+		c.block()
+	case atom.Br:
+		c.b.WriteString("\\\n")
+	case atom.Hr:
+		c.block()
+		c.b.WriteString("---")
+		c.block()
+	case atom.Strong, atom.B:
+		c.wrap(n, "**")
+	case atom.Em, atom.I:
+		c.wrap(n, "*")
+	case atom.Del, atom.S:
+		c.wrap(n, "~~")
+	case atom.Mark:
+		c.wrap(n, "==")
+	case atom.Sup:
+		c.wrap(n, "^")
+	case atom.Sub:
+		c.wrap(n, "~")
+	case atom.Code:
+		if c.inPre {
+			c.children(n)
+			return
+		}
+		c.inCode = true
+		c.wrap(n, "`")
+		c.inCode = false
+	case atom.Pre:
+		c.block()
+		c.b.WriteString("```\n")
+		c.inPre = true
+		c.children(n)
+		c.inPre = false
+		c.b.WriteString("\n```")
+		c.block()
+	case atom.A:
+		href := attr(n, "href")
+		if href == "" {
+			c.children(n)
+			return
+		}
+		c.b.WriteString("[")
+		c.children(n)
+		c.b.WriteString("](" + linkDestination(href) + ")")
+	case atom.Img:
+		alt := mdEscaper.Replace(collapseSpace(attr(n, "alt")))
+		c.b.WriteString("![" + alt + "](" + linkDestination(attr(n, "src")) + ")")
+	case atom.Blockquote:
+		inner := &mdConverter{}
+		inner.children(n)
+		c.block()
+		for _, line := range strings.Split(strings.TrimSpace(inner.b.String()), "\n") {
+			c.b.WriteString("> " + line + "\n")
+		}
+		c.block()
+	case atom.Ul, atom.Ol:
+		start := -1
+		if n.DataAtom == atom.Ol {
+			start = 1
+		}
+		if len(c.listStack) == 0 {
+			c.block()
+		}
+		c.listStack = append(c.listStack, start)
+		c.children(n)
+		c.listStack = c.listStack[:len(c.listStack)-1]
+		if len(c.listStack) == 0 {
+			c.block()
+		}
+	case atom.Li:
+		depth := len(c.listStack)
+		bullet := "- "
+		if depth > 0 && c.listStack[depth-1] > 0 {
+			bullet = fmt.Sprintf("%d. ", c.listStack[depth-1])
+			c.listStack[depth-1]++
+		}
+		if depth > 0 {
+			depth--
+		}
+		c.b.WriteString("\n" + strings.Repeat("  ", depth) + bullet)
+		c.children(n)
+	case atom.Td, atom.Th:
+		c.children(n)
+		c.b.WriteString(" ")
+	default:
+		c.children(n)
+	}
+}
+
+// mdEscaper backslash-escapes the characters that would otherwise turn plain
+// HTML text into emphasis, links or code spans.
+var mdEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "`", "\\`")
+
+// linkDestination formats url as a markdown link destination. One containing
+// spaces or parentheses is wrapped in <…>, which would otherwise end the link
+// early.
+func linkDestination(url string) string {
+	if !strings.ContainsAny(url, " ()<>") {
+		return url
+	}
+	return "<" + strings.NewReplacer("<", `\<`, ">", `\>`).Replace(url) + ">"
+}
+
+// collapseSpace folds runs of whitespace (including newlines) into one space,
+// the way HTML renders text.
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeft(s[:1], " \t\r\n") == "" {
+		out = " " + out
+	}
+	if strings.TrimRight(s[len(s)-1:], " \t\r\n") == "" {
+		out += " "
+	}
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestHTMLToMarkdownEscapes(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`<p>2 * 3 = 6, snake_case and [x]</p>`, `2 \* 3 = 6, snake\_case and \[x\]` + "\n"},
+		{"<p>use <code>a_b*c</code> or `x`</p>", "use `a_b*c` or \\`x\\`\n"},
+		{`<a href="https://example.com/a b">a</a>`, "[a](<https://example.com/a b>)\n"},
+		{`<a href="https://en.wikipedia.org/wiki/Go_(game)">Go</a>`, "[Go](<https://en.wikipedia.org/wiki/Go_(game)>)\n"},
+		{`<a href="https://example.com/x">x</a>`, "[x](https://example.com/x)\n"},
+		{`<img alt="a [b]" src="my pic.png">`, "![a \\[b\\]](<my pic.png>)\n"},
+		{"<pre>a * b_c</pre>", "```\na * b_c\n```\n"},
+	} {
+		got, err := htmlToMarkdown(tc.in)
+		if err != nil {
+			t.Fatalf("htmlToMarkdown(%q): %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Errorf("htmlToMarkdown(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestHTMLFragment(t *testing.T) {
+	cf := "Version:0.9\r\nStartHTML:0000000105\r\n<html><body><!--StartFragment--><b>hi</b><!--EndFragment--></body></html>"
+	if got := htmlFragment(cf); got != "<b>hi</b>" {
+		t.Errorf("htmlFragment = %q", got)
+	}
+	if got := htmlFragment("<b>hi</b>"); got != "<b>hi</b>" {
+		t.Errorf("htmlFragment without markers = %q", got)
+	}
+}
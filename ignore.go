@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Ignore patterns
+//
+// Files and folders can be hidden from the tree with gitignore-style
+// patterns, read from .marknoteignore in the open folder and from the
+// IgnorePatterns config list. Supported: "#" comments, "!" negation, a
+// trailing "/" for folders only, a leading or inner "/" to anchor a pattern
+// to the folder, and "*", "?", "[…]" and "**" wildcards. The last matching
+// pattern wins. The same matcher optionally applies .gitignore files,
+// including nested ones, when the folder is in a git work tree.
+// ---------------------------------------------------------------------------
+
+const ignoreFileName = ".marknoteignore"
+
+// ignoreRule is one parsed pattern line.
+type ignoreRule struct {
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes a match
+	dirOnly  bool     // "pattern/" only matches folders
+	anchored bool     // contains a "/", so it matches from the base folder
+}
+
+// ignoreMatcher is a set of rules relative to one folder.
+type ignoreMatcher struct {
+	base  string // folder the patterns are relative to
+	rules []ignoreRule
+}
+
+// parseIgnore compiles pattern lines for the folder base.
+func parseIgnore(base string, lines []string) *ignoreMatcher {
+	m := &ignoreMatcher{base: base}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // "\#" and "\!" escape a leading character
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		r.segments = strings.Split(line, "/")
+		m.rules = append(m.rules, r)
+	}
+	return m
+}
+
+// match reports whether p (an absolute path) is ignored, or false if no rule
+// decides it or p is outside the base folder.
+func (m *ignoreMatcher) match(p string, isDir bool) (ignored, decided bool) {
+	if m == nil || len(m.rules) == 0 {
+		return false, false
+	}
+	rel, err := filepath.Rel(m.base, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		if r.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if r.anchored {
+			ok = matchSegments(r.segments, parts)
+		} else {
+			ok = matchSegments(r.segments, parts[len(parts)-1:])
+		}
+		if ok {
+			return !r.negate, true
+		}
+	}
+	return false, false
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for any number of folders.
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// readIgnoreFile returns the lines of an ignore file, or nil if it does not
+// exist.
+func readIgnoreFile(name string) []string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// loadIgnore builds the matcher for the folder root from its .marknoteignore
+// and the IgnorePatterns setting, and finds the git work tree it is in.
+func (a *App) loadIgnore(root string) {
+	lines := append([]string(nil), a.cfg.IgnorePatterns...)
+	lines = append(lines, readIgnoreFile(filepath.Join(root, ignoreFileName))...)
+	a.ignore = parseIgnore(root, lines)
+	a.gitRoot = findGitRoot(root)
+	a.gitignores = nil
+}
+
+// ignored reports whether p is hidden by the ignore patterns, or, with
+// RespectGitignore set, by a .gitignore. .marknoteignore decides first.
+func (a *App) ignored(p string, isDir bool) bool {
+	if ignored, ok := a.ignore.match(p, isDir); ok {
+		return ignored
+	}
+	if !a.cfg.RespectGitignore || a.gitRoot == "" {
+		return false
+	}
+	// The nearest .gitignore that has an opinion wins, as in git.
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		if ignored, ok := a.gitignoreFor(dir).match(p, isDir); ok {
+			return ignored
+		}
+		if dir == a.gitRoot || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// gitignoreFor returns the compiled .gitignore of dir, cached per folder; nil
+// when it has none.
+func (a *App) gitignoreFor(dir string) *ignoreMatcher {
+	if m, ok := a.gitignores[dir]; ok {
+		return m
+	}
+	if a.gitignores == nil {
+		a.gitignores = make(map[string]*ignoreMatcher)
+	}
+	var m *ignoreMatcher
+	if lines := readIgnoreFile(filepath.Join(dir, ".gitignore")); lines != nil {
+		m = parseIgnore(dir, lines)
+	}
+	a.gitignores[dir] = m
+	return m
+}
+
+// findGitRoot returns the top of the git work tree containing dir, or "".
+func findGitRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Images
+//
+// A paragraph holding nothing but an image becomes an imageBlock. Blocks are
+// parsed without knowing where the note lives, so the preview pane resolves
+// each image the first time it draws the block and decodes it in a goroutine,
+// showing a placeholder meanwhile. Decoded images are cached by path and
+// modification time, least recently used first out once the cache outgrows
+// imageCacheBytes. Clicking an image opens it in a full-window viewer.
+// ---------------------------------------------------------------------------
+
+const (
+	// maxImageHeight caps the height of an image drawn inline in the preview.
+	maxImageHeight = 480
+	// imageCacheBytes bounds the pixel memory held by decoded images.
+	imageCacheBytes = 256 << 20
+)
+
+type imageBlock struct {
+	dest, alt string
+	img       *previewImage // set by the preview pane before layout
+	click     widget.Clickable
+}
+
+// previewImage is a decoded image file, or the reason it could not be shown.
+type previewImage struct {
+	src  paint.ImageOp
+	size image.Point
+	err  error
+}
+
+// imageEntry is one cached image file.
+type imageEntry struct {
+	img     *previewImage // nil while decoding
+	modTime time.Time
+	used    uint64 // imageClock at the last lookup
+}
+
+// imageResult is a finished decode, delivered to the frame loop.
+type imageResult struct {
+	path    string
+	modTime time.Time
+	img     *previewImage
+}
+
+// imageViewer is the state of the full-window image overlay.
+type imageViewer struct {
+	img   *previewImage
+	title string
+	zoom  float32 // relative to the fitted size
+}
+
+func (b *imageBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
+	if b.img == nil || b.img.err != nil {
+		text := "Image: " + b.dest
+		if b.alt != "" {
+			text = "Image: " + b.alt
+		}
+		if b.img == nil {
+			text += " …" // still decoding
+		} else {
+			text += " (" + b.img.err.Error() + ")"
+		}
+		return withBackground(gtx, darkenColor(th.Palette.Bg, 10), unit.Dp(8), func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Label(th, unit.Sp(12), text)
+			lbl.Color = mulAlpha(th.Palette.Fg, 150)
+			return lbl.Layout(gtx)
+		})
+	}
+	return b.click.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min = image.Point{}
+		gtx.Constraints.Max.Y = min(gtx.Constraints.Max.Y, gtx.Dp(maxImageHeight))
+		if st.ImageMaxWidth > 0 {
+			gtx.Constraints.Max.X = min(gtx.Constraints.Max.X, gtx.Dp(st.ImageMaxWidth))
+		}
+		return widget.Image{Src: b.img.src, Fit: widget.ScaleDown}.Layout(gtx)
+	})
+}
+
+// loadImage returns the image at dest, relative to the open note, or nil
+// while it is being decoded. Files are decoded again only when they change.
+func (a *App) loadImage(dest string) *previewImage {
+	if u, err := url.Parse(dest); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		return &previewImage{err: errors.New("remote images are not loaded")}
+	}
+	p, err := url.PathUnescape(strings.TrimPrefix(dest, "file://"))
+	if err != nil {
+		p = dest
+	}
+	p = filepath.FromSlash(p)
+	if !filepath.IsAbs(p) {
+		if a.currentFile == "" {
+			return &previewImage{err: errors.New("no note open")}
+		}
+		p = filepath.Join(filepath.Dir(a.currentFile), p)
+	}
+
+	e, ok := a.images[p]
+	if ok && e.img == nil {
+		return nil // still decoding
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return &previewImage{err: errors.New("not found")}
+	}
+	a.imageClock++
+	if ok && e.modTime.Equal(info.ModTime()) {
+		e.used = a.imageClock
+		return e.img
+	}
+	if a.images == nil {
+		a.images = make(map[string]*imageEntry)
+	}
+	a.images[p] = &imageEntry{modTime: info.ModTime(), used: a.imageClock}
+	a.tasks++
+	go func() {
+		a.imageCh <- imageResult{path: p, modTime: info.ModTime(), img: decodeImage(p)}
+		a.window.Invalidate()
+	}()
+	return nil
+}
+
+// applyImage caches a finished decode, then evicts the least recently used
+// images while the cache is over budget.
+func (a *App) applyImage(res imageResult) {
+	a.tasks--
+	a.images[res.path] = &imageEntry{img: res.img, modTime: res.modTime, used: a.imageClock}
+
+	total := 0
+	for _, e := range a.images {
+		total += e.bytes()
+	}
+	for total > imageCacheBytes {
+		oldest := ""
+		for p, e := range a.images {
+			if e.img != nil && p != res.path && (oldest == "" || e.used < a.images[oldest].used) {
+				oldest = p
+			}
+		}
+		if oldest == "" {
+			break
+		}
+		total -= a.images[oldest].bytes()
+		delete(a.images, oldest)
+	}
+}
+
+// bytes estimates the memory held by the decoded pixels.
+func (e *imageEntry) bytes() int {
+	if e.img == nil {
+		return 0
+	}
+	return e.img.size.X * e.img.size.Y * 4
+}
+
+func decodeImage(p string) *previewImage {
+	f, err := os.Open(p)
+	if err != nil {
+		return &previewImage{err: err}
+	}
+	defer f.Close()
+	m, _, err := image.Decode(f)
+	if err != nil {
+		return &previewImage{err: fmt.Errorf("cannot decode: %w", err)}
+	}
+	return &previewImage{src: paint.NewImageOp(m), size: m.Bounds().Size()}
+}
+
+// openImageViewer shows img in the full-window overlay at its fitted size.
+func (a *App) openImageViewer(gtx layout.Context, b *imageBlock) {
+	title := b.alt
+	if title == "" {
+		title = b.dest
+	}
+	a.viewer = &imageViewer{img: b.img, title: title, zoom: 1}
+	gtx.Execute(key.FocusCmd{Tag: a.viewer})
+}
+
+// closeImageViewer dismisses the overlay and hands focus back to the editor.
+func (a *App) closeImageViewer(gtx layout.Context) {
+	a.viewer = nil
+	gtx.Execute(key.FocusCmd{Tag: &a.editor})
+}
+
+// layoutImageViewer draws the open image over a dark scrim, shrunk to fit
+// the window and then scaled by the zoom. The wheel and +/-/0 zoom; a click
+// or Esc closes it.
+func (a *App) layoutImageViewer(gtx layout.Context) layout.Dimensions {
+	v := a.viewer
+	for {
+		e, ok := gtx.Event(
+			pointer.Filter{Target: v, Kinds: pointer.Press | pointer.Scroll, ScrollY: pointer.ScrollRange{Min: -1 << 20, Max: 1 << 20}},
+			key.Filter{Focus: v, Name: key.NameEscape},
+			key.Filter{Focus: v, Name: "+", Optional: key.ModShift},
+			key.Filter{Focus: v, Name: "=", Optional: key.ModShift},
+			key.Filter{Focus: v, Name: "-"},
+			key.Filter{Focus: v, Name: "0"},
+		)
+		if !ok {
+			break
+		}
+		switch e := e.(type) {
+		case pointer.Event:
+			if e.Kind == pointer.Press {
+				a.closeImageViewer(gtx)
+				return layout.Dimensions{}
+			}
+			if e.Scroll.Y < 0 {
+				v.zoom *= 1.25
+			} else if e.Scroll.Y > 0 {
+				v.zoom /= 1.25
+			}
+		case key.Event:
+			if e.State != key.Press {
+				continue
+			}
+			switch e.Name {
+			case key.NameEscape:
+				a.closeImageViewer(gtx)
+				return layout.Dimensions{}
+			case "+", "=":
+				v.zoom *= 1.25
+			case "-":
+				v.zoom /= 1.25
+			case "0":
+				v.zoom = 1
+			}
+		}
+	}
+	v.zoom = min(max(v.zoom, 0.1), 16)
+
+	size := gtx.Constraints.Max
+	paint.FillShape(gtx.Ops, color.NRGBA{A: 220}, clip.Rect{Max: size}.Op())
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, v)
+
+	// Fit within the window less a margin, never enlarging past 1:1.
+	margin := gtx.Dp(24)
+	img := v.img.size
+	fit := min(1, float32(size.X-2*margin)/float32(img.X), float32(size.Y-2*margin)/float32(img.Y))
+	gtx.Constraints = layout.Exact(size)
+	widget.Image{
+		Src:      v.img.src,
+		Position: layout.Center,
+		Scale:    fit * v.zoom / gtx.Metric.PxPerDp,
+	}.Layout(gtx)
+
+	caption := v.title
+	if v.zoom != 1 {
+		caption += fmt.Sprintf("  %.0f%%", fit*v.zoom*100)
+	}
+	layout.S.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Label(a.th, unit.Sp(12), caption)
+			lbl.Color = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 200}
+			lbl.MaxLines = 1
+			return lbl.Layout(gtx)
+		})
+	})
+	return layout.Dimensions{Size: size}
+}
@@ -3,18 +3,44 @@ package main
 import (
 	"log"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"gioui.org/app"
 )
 
+var (
+	// windows counts open windows; the process exits once the last closes.
+	windows sync.WaitGroup
+	// windowFailed records that some window ended with an error.
+	windowFailed atomic.Bool
+)
+
 func main() {
-	a := newApp()
+	go prunePrintPages()
+	newWindow(true)
 	go func() {
-		if err := a.run(); err != nil {
-			log.Println(err)
+		windows.Wait()
+		if windowFailed.Load() {
 			os.Exit(1)
 		}
 		os.Exit(0)
 	}()
 	app.Main()
 }
+
+// newWindow opens a Marknote window with its own App, so each has its own
+// folder and open file. Only the primary window restores the last session
+// and saves it on close.
+func newWindow(primary bool) {
+	a := newApp()
+	a.primary = primary
+	windows.Add(1)
+	go func() {
+		defer windows.Done()
+		if err := a.run(); err != nil {
+			log.Println(err)
+			windowFailed.Store(true)
+		}
+	}()
+}
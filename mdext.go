@@ -0,0 +1,211 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ---------------------------------------------------------------------------
+// Goldmark extensions for inline syntax beyond GFM
+// ---------------------------------------------------------------------------
+
+// markNode is ==highlighted== text.
+type markNode struct {
+	ast.BaseInline
+}
+
+var kindMark = ast.NewNodeKind("Mark")
+
+func (n *markNode) Kind() ast.NodeKind { return kindMark }
+
+func (n *markNode) Dump(src []byte, level int) { ast.DumpHelper(n, src, level, nil, nil) }
+
+type markDelimiterProcessor struct{}
+
+func (p *markDelimiterProcessor) IsDelimiter(b byte) bool { return b == '=' }
+
+func (p *markDelimiterProcessor) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (p *markDelimiterProcessor) OnMatch(consumes int) ast.Node { return &markNode{} }
+
+// markParser recognises "==" delimiters, the same way goldmark's
+// strikethrough parser handles "~~".
+type markParser struct{}
+
+func (p *markParser) Trigger() []byte { return []byte{'='} }
+
+func (p *markParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 2, &markDelimiterProcessor{})
+	if node == nil || node.OriginalLength != 2 || before == '=' {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+func (p *markParser) CloseBlock(parent ast.Node, pc parser.Context) {}
+
+type markHTMLRenderer struct{}
+
+func (r *markHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMark, func(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			w.WriteString("<mark>")
+		} else {
+			w.WriteString("</mark>")
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// markExtension adds ==highlight== to a goldmark instance.
+type markExtension struct{}
+
+func (e *markExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&markParser{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&markHTMLRenderer{}, 500)))
+}
+
+// ---------------------------------------------------------------------------
+// Superscript (^sup^) and subscript (~sub~)
+//
+// GFM also accepts a single tilde for strikethrough, so goldmark's
+// strikethrough extension is replaced by tildeParser: one tilde is subscript,
+// two are strikethrough, and an opener only matches a closer of the same
+// length.
+// ---------------------------------------------------------------------------
+
+type supNode struct {
+	ast.BaseInline
+}
+
+var kindSup = ast.NewNodeKind("Superscript")
+
+func (n *supNode) Kind() ast.NodeKind { return kindSup }
+
+func (n *supNode) Dump(src []byte, level int) { ast.DumpHelper(n, src, level, nil, nil) }
+
+type subNode struct {
+	ast.BaseInline
+}
+
+var kindSub = ast.NewNodeKind("Subscript")
+
+func (n *subNode) Kind() ast.NodeKind { return kindSub }
+
+func (n *subNode) Dump(src []byte, level int) { ast.DumpHelper(n, src, level, nil, nil) }
+
+// sameLengthDelimiters matches runs of one delimiter character only with runs
+// of equal length, so "~x~~" is not parsed as anything.
+type sameLengthDelimiters struct {
+	char    byte
+	onMatch func(consumes int) ast.Node
+}
+
+func (p *sameLengthDelimiters) IsDelimiter(b byte) bool { return b == p.char }
+
+func (p *sameLengthDelimiters) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char && opener.OriginalLength == closer.OriginalLength
+}
+
+func (p *sameLengthDelimiters) OnMatch(consumes int) ast.Node { return p.onMatch(consumes) }
+
+var (
+	tildeDelimiters = &sameLengthDelimiters{char: '~', onMatch: func(consumes int) ast.Node {
+		if consumes == 2 {
+			return extast.NewStrikethrough()
+		}
+		return &subNode{}
+	}}
+	caretDelimiters = &sameLengthDelimiters{char: '^', onMatch: func(int) ast.Node {
+		return &supNode{}
+	}}
+)
+
+// delimiterParser pushes delimiter runs of up to maxLen characters.
+type delimiterParser struct {
+	proc   *sameLengthDelimiters
+	maxLen int
+}
+
+func (p *delimiterParser) Trigger() []byte { return []byte{p.proc.char} }
+
+func (p *delimiterParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 1, p.proc)
+	if node == nil || node.OriginalLength > p.maxLen || before == rune(p.proc.char) {
+		return nil
+	}
+	if node.OriginalLength == 1 && node.CanOpen && !spanWithoutSpace(line[1:], p.proc.char) {
+		// As in Pandoc, a single ^ or ~ span cannot hold unescaped
+		// whitespace, so "e^x and e^y" stays plain text.
+		node.CanOpen = false
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+// spanWithoutSpace reports whether rest, the line after an opening
+// delimiter, reaches the next delim before any unescaped whitespace.
+func spanWithoutSpace(rest []byte, delim byte) bool {
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == '\\':
+			i++
+		case c == delim:
+			return true
+		case util.IsSpace(c):
+			return false
+		}
+	}
+	return false
+}
+
+func (p *delimiterParser) CloseBlock(parent ast.Node, pc parser.Context) {}
+
+type supSubHTMLRenderer struct{}
+
+func (r *supSubHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	tag := func(name string) renderer.NodeRendererFunc {
+		return func(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				w.WriteString("<" + name + ">")
+			} else {
+				w.WriteString("</" + name + ">")
+			}
+			return ast.WalkContinue, nil
+		}
+	}
+	reg.Register(kindSup, tag("sup"))
+	reg.Register(kindSub, tag("sub"))
+}
+
+// supSubExtension adds ^superscript^ and ~subscript~ along with ~~strike~~.
+// Use it instead of extension.Strikethrough, not alongside it.
+type supSubExtension struct{}
+
+func (e *supSubExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		util.Prioritized(&delimiterParser{proc: tildeDelimiters, maxLen: 2}, 500),
+		util.Prioritized(&delimiterParser{proc: caretDelimiters, maxLen: 1}, 500),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(extension.NewStrikethroughHTMLRenderer(), 500),
+		util.Prioritized(&supSubHTMLRenderer{}, 500),
+	))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func TestSupSubNoWhitespace(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(&supSubExtension{}))
+	tests := []struct{ in, want string }{
+		{"e^x and e^y", "<p>e^x and e^y</p>"},
+		{"5~10 and 20~30", "<p>5~10 and 20~30</p>"},
+		{"x^2^ and H~2~O", "<p>x<sup>2</sup> and H<sub>2</sub>O</p>"},
+		{`a^b\ c^`, `<p>a<sup>b\ c</sup></p>`}, // escaped space, as in Pandoc
+		{"~~struck text~~", "<p><del>struck text</del></p>"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(tt.in), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.want {
+			t.Errorf("%q renders as %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+const (
+	minimapWidth   = unit.Dp(72)
+	minimapRowMax  = unit.Dp(3) // row height for short documents
+	minimapColumns = 100        // line length that fills the strip
+)
+
+// minimap is a scaled overview of the editor text drawn as one bar per line,
+// with the visible part of the document shaded. Clicking or dragging moves
+// the caret to the corresponding line.
+type minimap struct {
+	lens []int // rune length of each line, refreshed on text changes
+}
+
+// setText recomputes the cached line lengths.
+func (m *minimap) setText(s string) {
+	lines := strings.Split(s, "\n")
+	m.lens = m.lens[:0]
+	for _, l := range lines {
+		m.lens = append(m.lens, utf8.RuneCountInString(strings.ReplaceAll(l, "\t", "    ")))
+	}
+}
+
+// lineOf returns the 0-based line containing rune offset pos.
+func (m *minimap) lineOf(pos int) int {
+	for i, n := range m.lens {
+		if pos <= n {
+			return i
+		}
+		pos -= n + 1
+	}
+	return max(0, len(m.lens)-1)
+}
+
+// layoutMinimap draws the minimap for an editor whose viewport is editorH
+// pixels tall. The editor does not expose its scroll offset, so the visible
+// range is derived from the caret's line and on-screen position, assuming one
+// row per line; wrapped lines make it approximate.
+func (a *App) layoutMinimap(gtx layout.Context, editorH int) layout.Dimensions {
+	m := &a.minimap
+	size := image.Pt(gtx.Dp(minimapWidth), gtx.Constraints.Max.Y)
+	n := max(len(m.lens), 1)
+	rowH := min(float32(gtx.Dp(minimapRowMax)), float32(size.Y)/float32(n))
+
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: m, Kinds: pointer.Press | pointer.Drag})
+		if !ok {
+			break
+		}
+		if pe, ok := e.(pointer.Event); ok {
+			a.goToLine(int(pe.Position.Y/rowH) + 1)
+		}
+	}
+
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, m)
+	pointer.CursorPointer.Add(gtx.Ops)
+	paint.FillShape(gtx.Ops, previewBg(a.th.Palette.Bg), clip.Rect{Max: size}.Op())
+
+	// Visible region.
+	lineH := float32(gtx.Sp(editorTextSize)) * a.editorLineScale()
+	start, _ := a.editor.Selection()
+	top := float32(m.lineOf(start)) - a.editor.CaretCoords().Y/lineH
+	top = max(top, 0)
+	visible := float32(editorH) / lineH
+	view := image.Rect(0, int(top*rowH), size.X, int((top+visible)*rowH)+1)
+	paint.FillShape(gtx.Ops, mulAlpha(a.th.Palette.ContrastBg, 40), clip.Rect(view).Op())
+
+	// One bar per line.
+	barColor := mulAlpha(a.th.Palette.Fg, 90)
+	pad := gtx.Dp(4)
+	barH := max(int(rowH*0.7), 1)
+	for i, l := range m.lens {
+		if l == 0 {
+			continue
+		}
+		w := min(l, minimapColumns) * (size.X - 2*pad) / minimapColumns
+		y := int(float32(i) * rowH)
+		paint.FillShape(gtx.Ops, barColor, clip.Rect{Min: image.Pt(pad, y), Max: image.Pt(pad+max(w, 1), y+barH)}.Op())
+	}
+	return layout.Dimensions{Size: size}
+}
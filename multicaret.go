@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+)
+
+// ---------------------------------------------------------------------------
+// Extra carets
+//
+// widget.Editor has a single caret, so further carets are kept beside it as
+// rune offsets. Alt+Click leaves a caret where the main one was and moves
+// the main one to the click; Alt+Shift+I puts one on every line of the
+// selection. Each change typed at the main caret is found by comparing the
+// text with the last copy, then made again at every extra caret in a single
+// edit. Moving the main caret leaves the extras where they are; Esc, a plain
+// click, or any other kind of change (an undo, a command rewriting lines)
+// drops them.
+// ---------------------------------------------------------------------------
+
+// extraCarets are the carets kept beside the editor's own.
+type extraCarets struct {
+	pos  []int  // rune offsets, ascending
+	text string // editor text the offsets refer to
+	sel  [2]int // the main selection then, ordered
+}
+
+func (a *App) hasExtraCarets() bool {
+	return len(a.carets.pos) > 0
+}
+
+// dropCarets removes the extra carets.
+func (a *App) dropCarets() {
+	a.carets = extraCarets{}
+}
+
+// addCaret adds an extra caret at pos, unless there is one already.
+func (a *App) addCaret(pos int) {
+	if i, found := slices.BinarySearch(a.carets.pos, pos); !found {
+		a.carets.pos = slices.Insert(a.carets.pos, i, pos)
+	}
+}
+
+// syncCarets records the text and main selection the extra carets refer to,
+// dropping any extra caret the main one has landed on.
+func (a *App) syncCarets() {
+	if !a.hasExtraCarets() {
+		return
+	}
+	a.carets.text = a.editor.Text()
+	a.carets.sel[0], a.carets.sel[1] = a.orderedSelection()
+	caret, _ := a.editor.Selection()
+	a.carets.pos = slices.DeleteFunc(a.carets.pos, func(p int) bool { return p == caret })
+}
+
+// handleCaretClicks adds a caret where the main one is on Alt+Click and
+// drops the extras on any other click. It runs before the editor's Update
+// moves the main caret to the click.
+func (a *App) handleCaretClicks(gtx layout.Context) {
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &a.carets, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		pe, ok := e.(pointer.Event)
+		if !ok || pe.Kind != pointer.Press {
+			continue
+		}
+		if !pe.Modifiers.Contain(key.ModAlt) || a.editor.ReadOnly {
+			a.dropCarets()
+			continue
+		}
+		caret, _ := a.editor.Selection()
+		first := !a.hasExtraCarets()
+		a.addCaret(caret)
+		if first {
+			a.carets.text = a.editor.Text()
+		}
+	}
+}
+
+// caretsOnLines puts a caret on every line the selection touches, at the
+// column the selection starts at, or the end of shorter lines. The main
+// caret takes the first line.
+func (a *App) caretsOnLines() {
+	runes := []rune(a.editor.Text())
+	selStart, _ := a.orderedSelection()
+	start, end := a.selectedLines(runes)
+	if lineEnd(runes, start) == end {
+		a.status = "Select several lines to put a caret on each"
+		return
+	}
+	col := selStart - start
+	a.dropCarets()
+	first := -1
+	for ls := start; ls <= end; {
+		le := lineEnd(runes, ls)
+		pos := min(ls+col, le)
+		if first < 0 {
+			first = pos
+		} else {
+			a.addCaret(pos)
+		}
+		ls = le + 1
+	}
+	a.editor.SetCaret(first, first)
+	a.syncCarets()
+	a.status = fmt.Sprintf("%d carets", len(a.carets.pos)+1)
+}
+
+// repeatEdit makes the change just made at the main caret again at every
+// extra caret: text typed or pasted there is inserted at each, and runes
+// deleted before or after it are deleted likewise. Any other change drops
+// the extras.
+func (a *App) repeatEdit() {
+	old, cur := []rune(a.carets.text), []rune(a.editor.Text())
+	if string(old) == string(cur) {
+		return
+	}
+	s0, e0 := a.carets.sel[0], a.carets.sel[1]
+	if e0 > len(old) {
+		a.dropCarets()
+		return
+	}
+	// The change replaced old[p:end] with ins, found by trimming the common
+	// prefix and suffix, but never past the main selection.
+	p := 0
+	for p < s0 && p < len(cur) && old[p] == cur[p] {
+		p++
+	}
+	s := 0
+	for s < len(old)-e0 && s < len(cur)-p && old[len(old)-1-s] == cur[len(cur)-1-s] {
+		s++
+	}
+	end := len(old) - s
+	ins := cur[p : len(cur)-s]
+	var before, after int // runes to delete around each extra caret
+	switch {
+	case p == s0 && end == e0:
+		// Typed or pasted over the selection.
+	case len(ins) == 0 && s0 == e0 && end == e0:
+		before = e0 - p
+	case len(ins) == 0 && s0 == e0 && p == s0:
+		after = end - s0
+	default:
+		a.dropCarets()
+		return
+	}
+
+	out := slices.Clone(cur)
+	ms, me := a.editor.Selection()
+	var moved []int
+	left, right := len(cur), 0 // the span of cur the extras change
+	// From the last caret back, so the offsets still to visit stay valid.
+	for i := len(a.carets.pos) - 1; i >= 0; i-- {
+		c := a.carets.pos[i]
+		switch {
+		case c < p:
+		case c > end:
+			c += len(cur) - len(old)
+		default:
+			continue // inside the main edit
+		}
+		lo, hi := max(c-before, 0), min(c+after, len(out))
+		if len(moved) > 0 {
+			hi = min(hi, moved[len(moved)-1])
+		}
+		lo = min(lo, hi)
+		out = slices.Replace(out, lo, hi, ins...)
+		d := len(ins) - (hi - lo)
+		for j := range moved {
+			moved[j] += d
+		}
+		if lo < ms {
+			ms += d
+		}
+		if lo < me {
+			me += d
+		}
+		moved = append(moved, lo+len(ins))
+		left = min(left, lo)
+		right = max(right, hi)
+	}
+	if len(moved) == 0 {
+		a.dropCarets()
+		return
+	}
+	slices.Reverse(moved)
+	a.editor.SetCaret(left, right)
+	a.editor.Insert(string(out[left : right+len(out)-len(cur)]))
+	a.editor.SetCaret(ms, me)
+	a.carets.pos = slices.Compact(moved)
+	a.syncCarets()
+}
+
+// layoutCarets draws the extra carets over the editor, of the given size,
+// and listens there for Alt+Click, letting clicks through to the editor.
+func (a *App) layoutCarets(gtx layout.Context, size image.Point) {
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	defer pointer.PassOp{}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, &a.carets)
+
+	runes := []rune(a.carets.text)
+	w := max(gtx.Dp(1), 1)
+	var regions []widget.Region
+	for _, c := range a.carets.pos {
+		// The caret sits before the rune at c or, at the end of a line, after
+		// the one before it.
+		var x int
+		var b image.Rectangle
+		found := false
+		if c < len(runes) {
+			if regions = a.editor.Regions(c, c+1, regions[:0]); len(regions) > 0 {
+				b, found = regions[0].Bounds, true
+				x = b.Min.X
+			}
+		}
+		if (!found || b.Dx() == 0) && c > 0 && c <= len(runes) && runes[c-1] != '\n' {
+			if regions = a.editor.Regions(c-1, c, regions[:0]); len(regions) > 0 {
+				b, found = regions[0].Bounds, true
+				x = b.Max.X
+			}
+		}
+		if !found || b.Dy() == 0 {
+			continue // scrolled out of view
+		}
+		paint.FillShape(gtx.Ops, a.th.Palette.Fg, clip.Rect{Min: image.Pt(x, b.Min.Y), Max: image.Pt(x+w, b.Max.Y)}.Op())
+	}
+}
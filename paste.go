@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/transfer"
+	"gioui.org/layout"
+)
+
+// pasteMode selects how clipboard text requested by requestPaste is inserted.
+type pasteMode int
+
+const (
+	// pasteSmart is the normal paste: a URL pasted over a selection wraps it
+	// as [selection](url), and an empty text clipboard falls back to images.
+	pasteSmart pasteMode = iota
+	pasteHTMLAsMarkdown
+)
+
+// requestPaste asks the OS for the clipboard text; it arrives as a
+// transfer.DataEvent handled by handlePaste on a later event pass. Nothing
+// is pasted while the editor is read-only, as in vim's normal mode.
+func (a *App) requestPaste(gtx layout.Context, mode pasteMode) {
+	if a.denyReadOnly() || a.editor.ReadOnly {
+		return
+	}
+	a.pasteMode = mode
+	gtx.Execute(clipboard.ReadCmd{Tag: &a.pasteTag})
+}
+
+// handlePaste inserts clipboard text delivered for requestPaste.
+func (a *App) handlePaste(gtx layout.Context) {
+	for {
+		e, ok := gtx.Event(transfer.TargetFilter{Target: &a.pasteTag, Type: "application/text"})
+		if !ok {
+			break
+		}
+		de, ok := e.(transfer.DataEvent)
+		if !ok {
+			continue
+		}
+		rc := de.Open()
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			a.notifyError(err)
+			continue
+		}
+		a.insertPasted(string(data))
+	}
+}
+
+// insertPasted inserts text at the caret according to the pending paste mode.
+func (a *App) insertPasted(text string) {
+	switch a.pasteMode {
+	case pasteHTMLAsMarkdown:
+		// The clipboard had no HTML flavour; its text may still be HTML source.
+		if looksLikeHTML(text) {
+			a.insertHTML(text)
+			return
+		}
+	case pasteSmart:
+		if text == "" {
+			// No text on the clipboard; it may hold an image instead.
+			a.requestImagePaste()
+			return
+		}
+		url := strings.TrimSpace(text)
+		if sel := a.editor.SelectedText(); sel != "" && isURL(url) {
+			text = "[" + sel + "](" + url + ")"
+		}
+	}
+	a.editor.Insert(text)
+}
+
+// htmlPaste is the clipboard's HTML flavour read off the UI goroutine.
+type htmlPaste struct {
+	html string
+	err  error
+}
+
+// requestHTMLPaste reads the clipboard's HTML flavour in a goroutine; the
+// result is delivered through htmlPasteCh and handled by applyHTMLPaste.
+func (a *App) requestHTMLPaste() {
+	if a.denyReadOnly() || a.editor.ReadOnly {
+		return
+	}
+	a.tasks++
+	go func() {
+		src, err := readClipboardHTML()
+		a.htmlPasteCh <- htmlPaste{html: src, err: err}
+		a.window.Invalidate()
+	}()
+}
+
+// applyHTMLPaste inserts clipboard HTML as markdown. Without an HTML flavour
+// it falls back to the plain text, converted only if it is HTML source.
+func (a *App) applyHTMLPaste(gtx layout.Context, p htmlPaste) {
+	if p.err != nil {
+		a.requestPaste(gtx, pasteHTMLAsMarkdown)
+		return
+	}
+	a.insertHTML(p.html)
+}
+
+// insertHTML converts src to markdown and inserts it at the caret.
+func (a *App) insertHTML(src string) {
+	md, err := htmlToMarkdown(src)
+	if err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.editor.Insert(md)
+	a.status = "Pasted HTML as markdown"
+}
+
+// isURL reports whether s is a single URL with a common scheme.
+func isURL(s string) bool {
+	if s == "" || strings.ContainsAny(s, " \t\n") {
+		return false
+	}
+	for _, scheme := range []string{"http://", "https://", "ftp://", "mailto:"} {
+		if len(s) > len(scheme) && strings.EqualFold(s[:len(scheme)], scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// imagePaste is a clipboard image read off the UI goroutine.
+type imagePaste struct {
+	data []byte
+	err  error
+}
+
+// requestImagePaste reads the clipboard image in a goroutine; the result is
+// delivered through imagePasteCh so the frame loop can save and insert it.
+func (a *App) requestImagePaste() {
+	if a.denyReadOnly() || a.editor.ReadOnly {
+		return
+	}
+	if a.currentFile == "" {
+		a.status = "Open a file before pasting an image"
+		return
+	}
+	a.tasks++
+	go func() {
+		data, err := readClipboardImage()
+		a.imagePasteCh <- imagePaste{data: data, err: err}
+		a.window.Invalidate()
+	}()
+}
+
+// insertPastedImage writes a clipboard image into the assets folder next to
+// the current file and inserts a markdown reference to it at the caret.
+func (a *App) insertPastedImage(p imagePaste) {
+	if p.err != nil {
+		a.notifyError(p.err)
+		return
+	}
+	if a.currentFile == "" {
+		return
+	}
+	assets := a.cfg.AssetsDir
+	if assets == "" {
+		assets = "assets"
+	}
+	dir := filepath.Join(filepath.Dir(a.currentFile), filepath.FromSlash(assets))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		a.notifyError(err)
+		return
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	name := "image-" + stamp + ".png"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("image-%s-%d.png", stamp, i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), p.data, 0644); err != nil {
+		a.notifyError(err)
+		return
+	}
+
+	a.editor.Insert("![](" + filepath.ToSlash(filepath.Join(assets, name)) + ")")
+	a.fileTree.Refresh()
+	a.notify("Saved image: " + filepath.Join(dir, name))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Pinned notes
+//
+// Pins are kept in the config per open folder, as slash-separated paths
+// relative to it, and drawn in a "Pinned" section above the tree that stays
+// put whatever is expanded.
+// ---------------------------------------------------------------------------
+
+// folderKey returns path relative to the open folder, as stored in Config.Pins
+// and ExpandedFolders.
+func (a *App) folderKey(path string) (string, bool) {
+	rel, err := filepath.Rel(a.rootPath, path)
+	if a.rootPath == "" || err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (a *App) isPinned(path string) bool {
+	key, ok := a.folderKey(path)
+	return ok && slices.Contains(a.cfg.Pins[a.rootPath], key)
+}
+
+// togglePin pins or unpins path and saves the config.
+func (a *App) togglePin(path string) {
+	key, ok := a.folderKey(path)
+	if !ok {
+		return
+	}
+	pins := a.cfg.Pins[a.rootPath]
+	if i := slices.Index(pins, key); i >= 0 {
+		pins = slices.Delete(pins, i, i+1)
+	} else {
+		pins = append(pins, key)
+	}
+	if a.cfg.Pins == nil {
+		a.cfg.Pins = make(map[string][]string)
+	}
+	if len(pins) == 0 {
+		delete(a.cfg.Pins, a.rootPath)
+	} else {
+		a.cfg.Pins[a.rootPath] = pins
+	}
+	a.saveConfig()
+}
+
+// pinnedPaths returns the absolute paths of the open folder's pins that
+// still exist.
+func (a *App) pinnedPaths() []string {
+	var paths []string
+	for _, key := range a.cfg.Pins[a.rootPath] {
+		p := filepath.Join(a.rootPath, filepath.FromSlash(key))
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// layoutPins draws the Pinned section. Clicking a pinned note opens it;
+// clicking a pinned folder reveals it in the tree.
+func (ft *FileTree) layoutPins(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	a := ft.app
+	paths := a.pinnedPaths()
+	if len(paths) == 0 {
+		return layout.Dimensions{}
+	}
+	if ft.pinBtns == nil {
+		ft.pinBtns = make(map[string]*widget.Clickable)
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(2), Left: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Label(th, unit.Sp(11), "PINNED")
+				lbl.Color = mulAlpha(th.Palette.Fg, 150)
+				lbl.Font.Weight = font.SemiBold
+				return lbl.Layout(gtx)
+			})
+		}),
+	}
+	for _, p := range paths {
+		btn, ok := ft.pinBtns[p]
+		if !ok {
+			btn = new(widget.Clickable)
+			ft.pinBtns[p] = btn
+		}
+		if btn.Clicked(gtx) {
+			a.revealInTree(p)
+			if info, err := os.Stat(p); err != nil || !info.IsDir() {
+				a.confirmSwitch(p)
+			}
+		}
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return material.Clickable(gtx, btn, func(gtx layout.Context) layout.Dimensions {
+				current := p == a.currentFile
+				fg := th.Palette.Fg
+				if current {
+					fg = th.Palette.ContrastFg
+				}
+				rec := op.Record(gtx.Ops)
+				dims := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							lbl := material.Label(th, unit.Sp(10), "♦ ")
+							lbl.Color = mulAlpha(th.Palette.ContrastBg, 220)
+							if current {
+								lbl.Color = fg
+							}
+							return lbl.Layout(gtx)
+						}),
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							lbl := material.Label(th, unit.Sp(13), filepath.Base(p))
+							lbl.Color = fg
+							lbl.MaxLines = 1
+							return lbl.Layout(gtx)
+						}),
+					)
+				})
+				call := rec.Stop()
+				if current {
+					paint.FillShape(gtx.Ops, mulAlpha(th.Palette.ContrastBg, 200), clip.Rect{Max: dims.Size}.Op())
+				}
+				call.Add(gtx.Ops)
+				return dims
+			})
+		}))
+	}
+	children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		// Divider between the pins and the tree.
+		size := image.Pt(gtx.Constraints.Max.X, gtx.Dp(1))
+		paint.FillShape(gtx.Ops, mulAlpha(th.Palette.Fg, 40), clip.Rect{Max: size}.Op())
+		return layout.Dimensions{Size: image.Pt(size.X, size.Y+gtx.Dp(2))}
+	}))
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
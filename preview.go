@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"slices"
+	"strconv"
 	"strings"
 
 	"gioui.org/font"
+	"gioui.org/io/key"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
+	"gioui.org/widget"
 	"gioui.org/widget/material"
 
 	"github.com/yuin/goldmark"
@@ -23,7 +27,32 @@ import (
 
 // renderedBlock is a drawable block-level element of the preview pane.
 type renderedBlock interface {
-	Layout(gtx layout.Context, th *material.Theme) layout.Dimensions
+	Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions
+}
+
+// previewStyle carries the user's preview typography settings to the blocks.
+type previewStyle struct {
+	// LineHeight scales the gap between lines of body text; 0 keeps Gio's
+	// default.
+	LineHeight float32
+	// Code colours code blocks; nil derives them from the app theme.
+	Code *codeScheme
+	// OnLink is called with the destination of a pressed link and the
+	// modifiers held.
+	OnLink func(dest string, mods key.Modifiers)
+	// ImageMaxWidth caps the width of inline images; 0 leaves them up to
+	// the column width.
+	ImageMaxWidth unit.Dp
+	// Mono is the typeface of code blocks and inline code.
+	Mono font.Typeface
+}
+
+// bodyLabel is a wrapping label for body text using the preview line height.
+func bodyLabel(th *material.Theme, st *previewStyle, size unit.Sp, txt string) material.LabelStyle {
+	lbl := material.Label(th, size, txt)
+	lbl.MaxLines = 0
+	lbl.LineHeightScale = st.LineHeight
+	return lbl
 }
 
 // ---------------------------------------------------------------------------
@@ -31,12 +60,21 @@ type renderedBlock interface {
 // ---------------------------------------------------------------------------
 
 type headingBlock struct {
-	level int
-	body  string
+	level  int
+	text   string // plain text, used to key fold state
+	spans  []span
+	number string // outline number such as "2.1", drawn when numbered is set
+	slug   string // anchor for "#slug" links, unique within the document
+
+	// Set by the preview pane before each layout: the fold chevron's button
+	// (nil draws none) and which way it points, and whether to show the
+	// number. Only the chevron folds, so links in the heading stay usable.
+	foldBtn          *widget.Clickable
+	folded, numbered bool
 }
 
 type paragraphBlock struct {
-	body string
+	spans []span
 }
 
 type codeBlock struct {
@@ -58,51 +96,209 @@ type listGroupBlock struct {
 type listItemBlock struct {
 	indent int
 	bullet string
-	body   string
+	spans  []span
 }
 
 type blockquoteBlock struct {
-	body string
+	body  string
+	alert *alertStyle // GitHub-style "> [!NOTE]" callout, or nil
+}
+
+// alertStyle is how one kind of "> [!TYPE]" callout is drawn.
+type alertStyle struct {
+	title string
+	icon  string
+	color color.NRGBA
+}
+
+// alertStyles are the callout types GitHub recognises, keyed by marker.
+var alertStyles = map[string]*alertStyle{
+	"NOTE":      {title: "Note", icon: "●", color: color.NRGBA{R: 0x09, G: 0x69, B: 0xDA, A: 0xFF}},
+	"TIP":       {title: "Tip", icon: "♦", color: color.NRGBA{R: 0x1A, G: 0x7F, B: 0x37, A: 0xFF}},
+	"IMPORTANT": {title: "Important", icon: "‼", color: color.NRGBA{R: 0x82, G: 0x50, B: 0xDF, A: 0xFF}},
+	"WARNING":   {title: "Warning", icon: "▲", color: color.NRGBA{R: 0x9A, G: 0x67, B: 0x00, A: 0xFF}},
+	"CAUTION":   {title: "Caution", icon: "■", color: color.NRGBA{R: 0xD1, G: 0x24, B: 0x2F, A: 0xFF}},
+}
+
+// newBlockquote builds a blockquote block, recognising a leading [!TYPE]
+// alert marker. Unknown types stay ordinary quotes.
+func newBlockquote(body string) *blockquoteBlock {
+	first, rest, _ := strings.Cut(body, "\n")
+	first = strings.TrimSpace(first)
+	if strings.HasPrefix(first, "[!") && strings.HasSuffix(first, "]") {
+		if st, ok := alertStyles[strings.ToUpper(first[2:len(first)-1])]; ok {
+			return &blockquoteBlock{body: strings.TrimSpace(rest), alert: st}
+		}
+	}
+	return &blockquoteBlock{body: body}
 }
 
 // ---------------------------------------------------------------------------
-// Parser (package-level so it's allocated once)
+// Parser (package-level so it's allocated once per flavor)
 // ---------------------------------------------------------------------------
 
-var mdParser = goldmark.New(
-	goldmark.WithExtensions(
-		extension.Table,
-		extension.Strikethrough,
-	),
+// Markdown flavors for Config.MarkdownFlavor.
+const (
+	flavorCommonMark = "commonmark" // no extensions
+	flavorGFM        = "gfm"        // tables, ~~strikethrough~~, task lists and bare-URL autolinks
+	flavorAll        = "all"        // GFM plus ==mark==, ^superscript^ and ~subscript~
+)
+
+// markdownFlavors are the flavors in the order the status bar offers them,
+// with flavorLabels naming each.
+var (
+	markdownFlavors = []string{flavorCommonMark, flavorGFM, flavorAll}
+	flavorLabels    = []string{"CommonMark", "GFM", "GFM+"}
 )
 
+// flavorIndex returns flavor's place in markdownFlavors; anything unknown
+// is flavorAll, as in newMarkdownParsers.
+func flavorIndex(flavor string) int {
+	if i := slices.Index(markdownFlavors, strings.ToLower(flavor)); i >= 0 {
+		return i
+	}
+	return len(markdownFlavors) - 1
+}
+
+// markdownParsers are one flavor's parsers, without and with the
+// typographic substitutions of Config.SmartQuotes.
+type markdownParsers struct {
+	plain, smart goldmark.Markdown
+}
+
+// mdTypographer curls quotes and turns --, --- and ... into dashes and an
+// ellipsis.
+var mdTypographer = extension.NewTypographer(
+	extension.WithTypographicSubstitutions(extension.TypographicSubstitutions{
+		extension.LeftSingleQuote:  []byte("‘"),
+		extension.RightSingleQuote: []byte("’"),
+		extension.LeftDoubleQuote:  []byte("“"),
+		extension.RightDoubleQuote: []byte("”"),
+		extension.EnDash:           []byte("–"),
+		extension.EmDash:           []byte("—"),
+		extension.Ellipsis:         []byte("…"),
+		extension.LeftAngleQuote:   []byte("«"),
+		extension.RightAngleQuote:  []byte("»"),
+		extension.Apostrophe:       []byte("’"),
+	}),
+)
+
+// newMarkdownParsers builds the parsers for flavor, one of the flavor
+// constants; anything else gets flavorAll. Each window keeps its own in
+// App.md and replaces them whole when the flavor changes, so an export
+// running in a goroutine keeps the flavor it started with.
+func newMarkdownParsers(flavor string) *markdownParsers {
+	var exts []goldmark.Extender
+	switch strings.ToLower(flavor) {
+	case flavorCommonMark:
+	case flavorGFM:
+		exts = []goldmark.Extender{extension.Table, extension.Strikethrough, extension.TaskList, extension.Linkify}
+	default:
+		exts = []goldmark.Extender{
+			extension.Table,
+			&supSubExtension{}, // also handles ~~strikethrough~~
+			&markExtension{},
+			extension.TaskList,
+			extension.Linkify,
+		}
+	}
+	return &markdownParsers{
+		plain: goldmark.New(goldmark.WithExtensions(exts...)),
+		smart: goldmark.New(goldmark.WithExtensions(append(slices.Clip(exts), mdTypographer)...)),
+	}
+}
+
+// parser returns the plain parser, or the typographic one when smart is set.
+func (p *markdownParsers) parser(smart bool) goldmark.Markdown {
+	if smart {
+		return p.smart
+	}
+	return p.plain
+}
+
+// renderOptions are the settings that change how markdown renders in the
+// preview.
+type renderOptions struct {
+	md        goldmark.Markdown // the window's parser, typographic if Config.SmartQuotes is set
+	html      string            // what to do with raw HTML (Config.PreviewHTML)
+	joinLines bool              // soft line breaks render as spaces (Config.JoinSoftBreaks)
+}
+
 // renderMarkdown parses markdown and returns a slice of renderedBlocks.
-func renderMarkdown(content string) []renderedBlock {
+func renderMarkdown(content string, opts renderOptions) []renderedBlock {
 	if strings.TrimSpace(content) == "" {
 		return nil
 	}
 	src := []byte(content)
 	reader := gmtext.NewReader(src)
-	doc := mdParser.Parser().Parse(reader)
+	doc := opts.md.Parser().Parse(reader)
 
 	var blocks []renderedBlock
 	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
-		if b := nodeToBlock(n, src, 0); b != nil {
+		if b := nodeToBlock(n, src, 0, opts); b != nil {
 			blocks = append(blocks, b)
 		}
 	}
+	numberHeadings(blocks)
+	slugs := slugger{}
+	for _, b := range blocks {
+		if h, ok := b.(*headingBlock); ok {
+			h.slug = slugs.slug(h.text)
+		}
+	}
 	return blocks
 }
 
-func nodeToBlock(n ast.Node, src []byte, listDepth int) renderedBlock {
+// numberHeadings assigns outline numbers (1, 1.1, 1.2, 2, …) to the headings
+// in blocks by nesting. Skipped levels do not add a component, so a "###"
+// directly under a "#" is numbered 1.1 rather than 1.0.1.
+func numberHeadings(blocks []renderedBlock) {
+	var levels, counts []int
+	for _, b := range blocks {
+		h, ok := b.(*headingBlock)
+		if !ok {
+			continue
+		}
+		// A heading shallower than a skipped-to level (## after ###) takes
+		// over that position's count.
+		prev := 0
+		for len(levels) > 0 && levels[len(levels)-1] > h.level {
+			prev = counts[len(counts)-1]
+			levels, counts = levels[:len(levels)-1], counts[:len(counts)-1]
+		}
+		if k := len(levels) - 1; k >= 0 && levels[k] == h.level {
+			counts[k]++
+		} else {
+			levels, counts = append(levels, h.level), append(counts, prev+1)
+		}
+		parts := make([]string, len(counts))
+		for i, c := range counts {
+			parts[i] = strconv.Itoa(c)
+		}
+		h.number = strings.Join(parts, ".")
+	}
+}
+
+func nodeToBlock(n ast.Node, src []byte, listDepth int, opts renderOptions) renderedBlock {
 	switch n := n.(type) {
 	case *ast.Heading:
-		return &headingBlock{level: n.Level, body: extractText(n, src)}
+		return &headingBlock{level: n.Level, text: extractText(n, src), spans: extractSpans(n, src, opts)}
 
 	case *ast.Paragraph:
-		return &paragraphBlock{body: extractText(n, src)}
+		if img, ok := n.FirstChild().(*ast.Image); ok && n.ChildCount() == 1 {
+			return &imageBlock{dest: string(img.Destination), alt: extractText(img, src)}
+		}
+		return &paragraphBlock{spans: extractSpans(n, src, opts)}
+
+	case *ast.HTMLBlock:
+		if spans := htmlBlockSpans(htmlBlockLines(n, src), opts.html); len(spans) > 0 {
+			return &paragraphBlock{spans: spans}
+		}
 
 	case *ast.FencedCodeBlock:
+		if lang := string(n.Language(src)); diagramTools[lang].command != nil {
+			return newDiagramBlock(lang, extractCodeLines(n, src))
+		}
 		return &codeBlock{code: extractCodeLines(n, src)}
 
 	case *ast.CodeBlock:
@@ -112,7 +308,7 @@ func nodeToBlock(n ast.Node, src []byte, listDepth int) renderedBlock {
 		return &hrBlock{}
 
 	case *ast.Blockquote:
-		return &blockquoteBlock{body: extractText(n, src)}
+		return newBlockquote(extractText(n, src))
 
 	case *ast.List:
 		var items []listItemBlock
@@ -130,7 +326,7 @@ func nodeToBlock(n ast.Node, src []byte, listDepth int) renderedBlock {
 			items = append(items, listItemBlock{
 				indent: listDepth,
 				bullet: bullet,
-				body:   extractText(li, src),
+				spans:  extractSpans(li, src, opts),
 			})
 		}
 		return &listGroupBlock{items: items}
@@ -141,6 +337,47 @@ func nodeToBlock(n ast.Node, src []byte, listDepth int) renderedBlock {
 	return nil
 }
 
+// ---------------------------------------------------------------------------
+// Heading folds
+// ---------------------------------------------------------------------------
+
+// headingKeys returns a fold key for each heading block ("" for other
+// blocks): its level and text, plus an occurrence count so repeated headings
+// fold independently.
+func headingKeys(blocks []renderedBlock) []string {
+	keys := make([]string, len(blocks))
+	seen := map[string]int{}
+	for i, b := range blocks {
+		if h, ok := b.(*headingBlock); ok {
+			k := fmt.Sprintf("%d:%s", h.level, h.text)
+			keys[i] = fmt.Sprintf("%s#%d", k, seen[k])
+			seen[k]++
+		}
+	}
+	return keys
+}
+
+// visibleBlocks returns the indices of blocks not hidden under a folded
+// heading. A fold runs to the next heading of the same or higher level.
+func visibleBlocks(blocks []renderedBlock, keys []string, folded map[string]bool) []int {
+	vis := make([]int, 0, len(blocks))
+	hideBelow := 0 // level of the folded heading being skipped, 0 if none
+	for i, b := range blocks {
+		h, isHeading := b.(*headingBlock)
+		if isHeading && hideBelow > 0 && h.level <= hideBelow {
+			hideBelow = 0
+		}
+		if hideBelow > 0 {
+			continue
+		}
+		vis = append(vis, i)
+		if isHeading && folded[keys[i]] {
+			hideBelow = h.level
+		}
+	}
+	return vis
+}
+
 // ---------------------------------------------------------------------------
 // Text extraction
 // ---------------------------------------------------------------------------
@@ -158,6 +395,8 @@ func extractText(n ast.Node, src []byte) string {
 			b.Write(tc.Value)
 		case *ast.RawHTML:
 			// skip
+		case *extast.TaskCheckBox:
+			b.WriteString(taskBox(tc.IsChecked))
 		default:
 			b.WriteString(extractText(c, src))
 		}
@@ -165,6 +404,14 @@ func extractText(n ast.Node, src []byte) string {
 	return strings.TrimSpace(b.String())
 }
 
+// taskBox is the box drawn for a task list item's [ ] or [x].
+func taskBox(checked bool) string {
+	if checked {
+		return "☑ "
+	}
+	return "☐ "
+}
+
 func extractCodeLines(n ast.Node, src []byte) string {
 	var b strings.Builder
 	for i := 0; i < n.Lines().Len(); i++ {
@@ -203,7 +450,7 @@ func buildTableBlock(n *extast.Table, src []byte) *tableBlock {
 
 var headingSizes = [7]unit.Sp{0, 22, 19, 16, 15, 14, 13}
 
-func (b *headingBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *headingBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	lvl := b.level
 	if lvl < 1 {
 		lvl = 1
@@ -211,31 +458,55 @@ func (b *headingBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dim
 	if lvl > 6 {
 		lvl = 6
 	}
+	rt := richText{size: headingSizes[lvl], font: font.Font{Weight: font.Bold}}
+	spans := b.spans
+	if b.numbered && b.number != "" {
+		spans = append([]span{{text: b.number + " "}}, spans...)
+	}
 	return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		lbl := material.Label(th, headingSizes[lvl], b.body)
-		lbl.Font = font.Font{Weight: font.Bold}
-		return lbl.Layout(gtx)
+		if b.foldBtn == nil {
+			return layoutSpans(gtx, th, st, rt, spans)
+		}
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Baseline}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				chevron := "▼"
+				if b.folded {
+					chevron = "▶"
+				}
+				lbl := material.Label(th, rt.size*0.6, chevron)
+				lbl.Color = mulAlpha(th.Palette.Fg, 140)
+				return layout.Inset{Right: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return material.Clickable(gtx, b.foldBtn, lbl.Layout)
+				})
+			}),
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				return layoutSpans(gtx, th, st, rt, spans)
+			}),
+		)
 	})
 }
 
-func (b *paragraphBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
-	lbl := material.Label(th, unit.Sp(14), b.body)
-	lbl.MaxLines = 0
-	return lbl.Layout(gtx)
+func (b *paragraphBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
+	return layoutSpans(gtx, th, st, richText{size: unit.Sp(14)}, b.spans)
 }
 
-func (b *codeBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *codeBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		return withBackground(gtx, darkenColor(th.Palette.Bg, 18), unit.Dp(8), func(gtx layout.Context) layout.Dimensions {
+		bg, fg := darkenColor(th.Palette.Bg, 18), th.Palette.Fg
+		if st.Code != nil {
+			bg, fg = st.Code.bg, st.Code.fg
+		}
+		return withBackground(gtx, bg, unit.Dp(8), func(gtx layout.Context) layout.Dimensions {
 			lbl := material.Label(th, unit.Sp(12), b.code)
 			lbl.MaxLines = 0
-			lbl.Font = font.Font{Typeface: "Go Mono"}
+			lbl.Font.Typeface = st.Mono
+			lbl.Color = fg
 			return lbl.Layout(gtx)
 		})
 	})
 }
 
-func (b *hrBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *hrBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		size := image.Pt(gtx.Constraints.Max.X, gtx.Dp(1))
 		paint.FillShape(gtx.Ops, mulAlpha(th.Palette.Fg, 80), clip.Rect{Max: size}.Op())
@@ -243,19 +514,19 @@ func (b *hrBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensio
 	})
 }
 
-func (b *listGroupBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *listGroupBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	items := b.items
 	var children []layout.FlexChild
 	for i := range items {
 		it := &items[i]
 		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return it.Layout(gtx, th)
+			return it.Layout(gtx, th, st)
 		}))
 	}
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
 }
 
-func (b *listItemBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *listItemBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	indent := unit.Dp(float32(b.indent*16 + 8))
 	return layout.Inset{Left: indent}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
@@ -263,15 +534,16 @@ func (b *listItemBlock) Layout(gtx layout.Context, th *material.Theme) layout.Di
 				return material.Label(th, unit.Sp(14), b.bullet).Layout(gtx)
 			}),
 			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-				lbl := material.Label(th, unit.Sp(14), b.body)
-				lbl.MaxLines = 0
-				return lbl.Layout(gtx)
+				return layoutSpans(gtx, th, st, richText{size: unit.Sp(14)}, b.spans)
 			}),
 		)
 	})
 }
 
-func (b *blockquoteBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+func (b *blockquoteBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
+	if b.alert != nil {
+		return b.layoutAlert(gtx, th, st)
+	}
 	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			size := image.Pt(gtx.Dp(4), 1)
@@ -280,15 +552,45 @@ func (b *blockquoteBlock) Layout(gtx layout.Context, th *material.Theme) layout.
 			return layout.Dimensions{Size: image.Pt(gtx.Dp(12), size.Y)}
 		}),
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-			lbl := material.Label(th, unit.Sp(13), b.body)
+			lbl := bodyLabel(th, st, unit.Sp(13), b.body)
 			lbl.Color = mulAlpha(th.Palette.Fg, 180)
-			lbl.MaxLines = 0
 			return lbl.Layout(gtx)
 		}),
 	)
 }
 
-func (b *tableBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+// layoutAlert draws a callout: a tinted box with a coloured left border and
+// an icon and title line above the body.
+func (b *blockquoteBlock) layoutAlert(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
+	al := b.alert
+	rec := op.Record(gtx.Ops)
+	dims := layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(14), Right: unit.Dp(10)}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Label(th, unit.Sp(13), al.icon+"  "+al.title)
+					lbl.Font.Weight = font.Bold
+					lbl.Color = al.color
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if b.body == "" {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, bodyLabel(th, st, unit.Sp(13), b.body).Layout)
+				}),
+			)
+		})
+	call := rec.Stop()
+
+	dims.Size.X = gtx.Constraints.Max.X
+	paint.FillShape(gtx.Ops, mulAlpha(al.color, 28), clip.Rect{Max: dims.Size}.Op())
+	paint.FillShape(gtx.Ops, al.color, clip.Rect{Max: image.Pt(gtx.Dp(4), dims.Size.Y)}.Op())
+	call.Add(gtx.Ops)
+	return dims
+}
+
+func (b *tableBlock) Layout(gtx layout.Context, th *material.Theme, st *previewStyle) layout.Dimensions {
 	return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		if b.numCols == 0 {
 			return layout.Dimensions{}
@@ -301,7 +603,7 @@ func (b *tableBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimen
 		headerCells := b.headers
 		numCols := b.numCols
 		rows = append(rows, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return tableRow(gtx, th, headerCells, numCols, colW, true)
+			return tableRow(gtx, th, st, headerCells, numCols, colW, true)
 		}))
 		rows = append(rows, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			size := image.Pt(gtx.Constraints.Max.X, gtx.Dp(1))
@@ -311,14 +613,14 @@ func (b *tableBlock) Layout(gtx layout.Context, th *material.Theme) layout.Dimen
 		for _, dr := range b.rows {
 			cells := dr
 			rows = append(rows, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return tableRow(gtx, th, cells, numCols, colW, false)
+				return tableRow(gtx, th, st, cells, numCols, colW, false)
 			}))
 		}
 		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
 	})
 }
 
-func tableRow(gtx layout.Context, th *material.Theme, cells []string, numCols, colW int, header bool) layout.Dimensions {
+func tableRow(gtx layout.Context, th *material.Theme, st *previewStyle, cells []string, numCols, colW int, header bool) layout.Dimensions {
 	var cols []layout.FlexChild
 	for i := 0; i < numCols; i++ {
 		idx := i
@@ -330,11 +632,10 @@ func tableRow(gtx layout.Context, th *material.Theme, cells []string, numCols, c
 			gtx.Constraints.Max.X = colW
 			gtx.Constraints.Min.X = colW
 			return layout.UniformInset(unit.Dp(3)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-				lbl := material.Label(th, unit.Sp(13), cell)
+				lbl := bodyLabel(th, st, unit.Sp(13), cell)
 				if header {
-					lbl.Font = font.Font{Weight: font.Bold}
+					lbl.Font.Weight = font.Bold
 				}
-				lbl.MaxLines = 0
 				return lbl.Layout(gtx)
 			})
 		}))
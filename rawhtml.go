@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"golang.org/x/net/html"
+)
+
+// ---------------------------------------------------------------------------
+// Raw HTML in the preview
+//
+// Config.PreviewHTML decides what the preview does with HTML written in a
+// note. By default it is dropped; "text" shows it as typed, and "safe"
+// renders a small set of formatting tags (b, i, a, br and the like) with the
+// usual span styles, dropping any other tag but keeping the text between
+// them. The markup never reaches anything that could run it.
+// ---------------------------------------------------------------------------
+
+const (
+	htmlDrop = "drop"
+	htmlText = "text"
+	htmlSafe = "safe"
+)
+
+// htmlTagStyles are the safe tags that style the text they enclose.
+var htmlTagStyles = map[string]spanStyle{
+	"b": styleBold, "strong": styleBold,
+	"i": styleItalic, "em": styleItalic,
+	"s": styleStrike, "del": styleStrike, "strike": styleStrike,
+	"code": styleCode, "kbd": styleCode,
+	"mark": styleMark,
+	"sup":  styleSup,
+	"sub":  styleSub,
+}
+
+// htmlLineTags end a line when they close, so block markup keeps its shape.
+var htmlLineTags = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+var htmlSpaceRe = regexp.MustCompile(`\s+`)
+
+// htmlFormat tracks what the safe tags seen so far apply to the text that
+// follows them.
+type htmlFormat struct {
+	style spanStyle
+	link  string
+	skip  bool // inside <script> or <style>, whose content is not text
+}
+
+// tag applies a piece of inline HTML, normally one tag, and returns the
+// text it stands for.
+func (f *htmlFormat) tag(raw string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(raw))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.TextToken:
+			if !f.skip {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			b.WriteString(f.apply(z.Token()))
+		}
+	}
+}
+
+// apply applies one tag and returns the text it stands for: a line break
+// for <br> and the end of block tags, otherwise nothing.
+func (f *htmlFormat) apply(t html.Token) string {
+	closing := t.Type == html.EndTagToken
+	switch name := t.Data; {
+	case name == "br":
+		return "\n"
+	case name == "script" || name == "style":
+		f.skip = !closing && t.Type != html.SelfClosingTagToken
+	case name == "a":
+		f.link = ""
+		if !closing {
+			f.link = safeHref(t.Attr)
+		}
+	case htmlLineTags[name]:
+		if closing {
+			return "\n"
+		}
+	default:
+		if s, ok := htmlTagStyles[name]; ok {
+			if closing {
+				f.style &^= s
+			} else {
+				f.style |= s
+			}
+		}
+	}
+	return ""
+}
+
+// safeHref returns a tag's href if it is a web, mail or relative link, or "".
+func safeHref(attrs []html.Attribute) string {
+	for _, at := range attrs {
+		if at.Key != "href" {
+			continue
+		}
+		u, err := url.Parse(at.Val)
+		if err != nil {
+			return ""
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "", "http", "https", "mailto":
+			return at.Val
+		}
+	}
+	return ""
+}
+
+// rawHTML returns the markup of an inline HTML node.
+func rawHTML(n *ast.RawHTML, src []byte) string {
+	var b strings.Builder
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		b.Write(seg.Value(src))
+	}
+	return b.String()
+}
+
+// htmlBlockLines returns the markup of a block of HTML.
+func htmlBlockLines(n *ast.HTMLBlock, src []byte) string {
+	raw := extractCodeLines(n, src)
+	if n.HasClosure() {
+		raw += "\n" + string(n.ClosureLine.Value(src))
+	}
+	return strings.TrimRight(raw, "\n")
+}
+
+// htmlBlockSpans renders a block of HTML in the given mode, returning no
+// spans when there is nothing to show.
+func htmlBlockSpans(raw, mode string) []span {
+	switch mode {
+	case htmlText:
+		return []span{{text: raw}}
+	case htmlSafe:
+	default:
+		return nil
+	}
+	var f htmlFormat
+	var spans []span
+	z := html.NewTokenizer(strings.NewReader(raw))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return trimSpans(spans)
+		case html.TextToken:
+			if !f.skip {
+				text := htmlSpaceRe.ReplaceAllString(string(z.Text()), " ")
+				spans = appendSpan(spans, text, f.style, f.link)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			spans = appendSpan(spans, f.apply(z.Token()), f.style, f.link)
+		}
+	}
+}
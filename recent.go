@@ -0,0 +1,135 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// ---------------------------------------------------------------------------
+// Recent files
+//
+// Config.RecentFiles lists the files opened most recently, newest first, by
+// full path and across folders. Ctrl+Shift+E shows them in a card over a
+// scrim; clicking one reopens it.
+// ---------------------------------------------------------------------------
+
+// rememberFile moves path to the front of the recent files and saves the
+// config.
+func (a *App) rememberFile(path string) {
+	n := a.cfg.RecentFilesMax
+	if n <= 0 {
+		return
+	}
+	recent := slices.DeleteFunc(a.cfg.RecentFiles, func(p string) bool { return p == path })
+	recent = append([]string{path}, recent...)
+	a.cfg.RecentFiles = recent[:min(len(recent), n)]
+	a.saveConfig()
+}
+
+// toggleRecent shows or hides the recent files card, first dropping files
+// that no longer exist.
+func (a *App) toggleRecent() {
+	a.showRecent = !a.showRecent
+	if a.showRecent {
+		n := len(a.cfg.RecentFiles)
+		a.cfg.RecentFiles = slices.DeleteFunc(a.cfg.RecentFiles, func(p string) bool {
+			_, err := os.Stat(p)
+			return err != nil
+		})
+		if len(a.cfg.RecentFiles) != n {
+			a.saveConfig()
+		}
+	}
+	a.window.Invalidate()
+}
+
+// layoutRecent draws the recent files as clickable rows, with the folder of
+// each dimmed beside its name.
+func (a *App) layoutRecent(gtx layout.Context) layout.Dimensions {
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &a.showRecent, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if _, ok := e.(pointer.Event); ok {
+			a.showRecent = false
+			return layout.Dimensions{}
+		}
+	}
+	for {
+		if _, ok := gtx.Event(pointer.Filter{Target: &a.recentBtns, Kinds: pointer.Press}); !ok {
+			break
+		}
+	}
+	files := a.cfg.RecentFiles
+	for len(a.recentBtns) < len(files) {
+		a.recentBtns = append(a.recentBtns, widget.Clickable{})
+	}
+	for i, p := range files {
+		if a.recentBtns[i].Clicked(gtx) {
+			a.showRecent = false
+			a.revealInTree(p)
+			if p != a.currentFile {
+				a.confirmSwitch(p)
+			}
+			return layout.Dimensions{}
+		}
+	}
+
+	paint.FillShape(gtx.Ops, color.NRGBA{A: 150}, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	scrim := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &a.showRecent)
+	scrim.Pop()
+
+	return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min.X = min(gtx.Dp(480), gtx.Constraints.Max.X)
+		gtx.Constraints.Max.X = gtx.Constraints.Min.X
+		return withBackground(gtx, previewBg(a.th.Palette.Bg), unit.Dp(16), func(gtx layout.Context) layout.Dimensions {
+			// Swallow clicks on the card so they do not reach the scrim.
+			defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+			event.Op(gtx.Ops, &a.recentBtns)
+
+			children := []layout.FlexChild{
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					title := material.H6(a.th, "Recent Files")
+					return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, title.Layout)
+				}),
+			}
+			if len(files) == 0 {
+				children = append(children, layout.Rigid(material.Body2(a.th, "No recent files").Layout))
+			}
+			for i, p := range files {
+				children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return material.Clickable(gtx, &a.recentBtns[i], func(gtx layout.Context) layout.Dimensions {
+						return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Baseline}.Layout(gtx,
+								layout.Rigid(material.Body1(a.th, filepath.Base(p)).Layout),
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									lbl := material.Caption(a.th, filepath.Dir(p))
+									lbl.Color = mulAlpha(a.th.Palette.Fg, 140)
+									lbl.MaxLines = 1
+									return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, lbl.Layout)
+								}),
+							)
+						})
+					})
+				}))
+			}
+			dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+			dims.Size = image.Pt(gtx.Constraints.Max.X, dims.Size.Y)
+			return dims
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// recoveryPath returns where the crash-recovery snapshot for file is kept, or
+// "" if there is no user cache directory. Snapshots are named by a hash of the
+// absolute path so notes with the same name in different folders don't clash.
+func recoveryPath(file string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil || file == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	sum := sha256.Sum256([]byte(file))
+	return filepath.Join(dir, "marknote", "recovery", hex.EncodeToString(sum[:8])+".md")
+}
+
+// removeRecovery deletes the snapshot for file, if any.
+func removeRecovery(file string) {
+	if p := recoveryPath(file); p != "" {
+		os.Remove(p)
+	}
+}
+
+// writeRecovery snapshots the editor buffer every RecoveryInterval seconds
+// while it has unsaved changes. The user's file is never touched.
+func (a *App) writeRecovery(gtx layout.Context) {
+	interval := time.Duration(a.cfg.RecoveryInterval) * time.Second
+	if interval <= 0 || a.currentFile == "" || !a.modified {
+		return
+	}
+	if next := a.recoveryAt.Add(interval); gtx.Now.Before(next) {
+		gtx.Execute(op.InvalidateCmd{At: next})
+		return
+	}
+	a.recoveryAt = gtx.Now
+
+	text := a.docText()
+	if text == a.recoveryText {
+		return
+	}
+	p := recoveryPath(a.currentFile)
+	if p == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		a.notifyError(err)
+		return
+	}
+	if err := os.WriteFile(p, []byte(text), 0600); err != nil {
+		a.notifyError(err)
+		return
+	}
+	a.recoveryText = text
+}
+
+// offerRecovery asks whether to restore a snapshot of path that is newer than
+// the file on disk and differs from content, its freshly loaded text.
+func (a *App) offerRecovery(path, content string) {
+	p := recoveryPath(path)
+	if p == "" {
+		return
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(p)
+	if err != nil || !info.ModTime().After(a.savedAt) || string(data) == content {
+		os.Remove(p)
+		return
+	}
+	m := a.showConfirmModal(
+		"Recover Unsaved Changes",
+		fmt.Sprintf("'%s' has changes from %s that were never saved. Restore them?",
+			filepath.Base(path), info.ModTime().Format("Jan 2 15:04")),
+		func() {
+			if a.currentFile != path {
+				return
+			}
+			recovered := string(data)
+			a.editor.SetText(recovered)
+			a.recoveryText = recovered
+			a.modified = true
+			a.previewBlocks = renderMarkdown(recovered, a.renderOptions())
+			a.updateTitle()
+		},
+		func() { os.Remove(p) },
+	)
+	m.okLabel = "Restore"
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"unicode"
+
+	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// ---------------------------------------------------------------------------
+// Inline spans
+//
+// Paragraphs, headings and list items keep their inline formatting as a list
+// of styled spans. layoutSpans flows them word by word, since a Gio label can
+// only draw one style.
+// ---------------------------------------------------------------------------
+
+// spanStyle is a set of inline formatting attributes.
+type spanStyle uint8
+
+const (
+	styleBold spanStyle = 1 << iota
+	styleItalic
+	styleCode
+	styleStrike
+	styleMark
+	styleSup
+	styleSub
+)
+
+// span is a run of text with one style. Text may contain '\n' for line
+// breaks.
+type span struct {
+	text  string
+	style spanStyle
+	link  string // destination when the span is link text
+}
+
+// extractSpans flattens the inline children of n into styled spans. Line
+// breaks are kept as '\n', like extractText, except that opts.joinLines
+// turns soft breaks into spaces. Inline HTML is handled as
+// opts.html says (see rawhtml.go).
+func extractSpans(n ast.Node, src []byte, opts renderOptions) []span {
+	var spans []span
+	var walk func(n ast.Node, style spanStyle, link string)
+	var hf htmlFormat
+	add := func(text string, style spanStyle, link string) {
+		if hf.skip {
+			return
+		}
+		if hf.link != "" && link == "" {
+			link = hf.link
+		}
+		spans = appendSpan(spans, text, style|hf.style, link)
+	}
+	walk = func(n ast.Node, style spanStyle, link string) {
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			switch c := c.(type) {
+			case *ast.Text:
+				add(string(c.Segment.Value(src)), style, link)
+				switch {
+				case c.HardLineBreak():
+					add("\n", style, link)
+				case c.SoftLineBreak():
+					if opts.joinLines {
+						add(" ", style, link)
+					} else {
+						add("\n", style, link)
+					}
+				}
+			case *ast.String:
+				add(string(c.Value), style, link)
+			case *ast.RawHTML:
+				switch opts.html {
+				case htmlText:
+					add(rawHTML(c, src), style, link)
+				case htmlSafe:
+					add(hf.tag(rawHTML(c, src)), style, link)
+				}
+			case *extast.TaskCheckBox:
+				add(taskBox(c.IsChecked), style, link)
+			case *ast.CodeSpan:
+				add(extractText(c, src), style|styleCode, link)
+			case *ast.Link:
+				walk(c, style, string(c.Destination))
+			case *ast.AutoLink:
+				add(string(c.Label(src)), style, string(c.URL(src)))
+			case *ast.Emphasis:
+				if c.Level >= 2 {
+					walk(c, style|styleBold, link)
+				} else {
+					walk(c, style|styleItalic, link)
+				}
+			case *extast.Strikethrough:
+				walk(c, style|styleStrike, link)
+			case *markNode:
+				walk(c, style|styleMark, link)
+			case *supNode:
+				walk(c, style|styleSup, link)
+			case *subNode:
+				walk(c, style|styleSub, link)
+			default:
+				walk(c, style, link)
+			}
+		}
+	}
+	walk(n, 0, "")
+	return trimSpans(spans)
+}
+
+// appendSpan adds text to spans, extending the last span when it has the
+// same style and link.
+func appendSpan(spans []span, text string, style spanStyle, link string) []span {
+	if text == "" {
+		return spans
+	}
+	if k := len(spans) - 1; k >= 0 && spans[k].style == style && spans[k].link == link {
+		spans[k].text += text
+		return spans
+	}
+	return append(spans, span{text: text, style: style, link: link})
+}
+
+// trimSpans trims like extractText so blocks don't start or end with blank
+// lines.
+func trimSpans(spans []span) []span {
+	if len(spans) > 0 {
+		spans[0].text = strings.TrimLeftFunc(spans[0].text, unicode.IsSpace)
+		k := len(spans) - 1
+		spans[k].text = strings.TrimRightFunc(spans[k].text, unicode.IsSpace)
+	}
+	return spans
+}
+
+// richText describes how to draw a list of spans.
+type richText struct {
+	size  unit.Sp
+	font  font.Font   // base font, theme face if unset; span styles are applied on top
+	color color.NRGBA // text color; zero means the theme foreground
+}
+
+// word is one shaped, unbreakable piece of a span.
+type word struct {
+	call      op.CallOp
+	dims      layout.Dimensions
+	style     spanStyle
+	spaceW    int   // width of a space in this word's font
+	rise      int   // baseline shift upwards, for superscript and subscript
+	space     bool  // preceded by whitespace
+	lineBreak bool  // starts a new line
+	link      *span // the link span the word belongs to, also its pointer tag
+}
+
+// layoutSpans draws spans as wrapped text. Words are placed left to right and
+// wrapped at the constraint width, with baselines aligned within each line.
+// Link text is underlined, and pressing it calls st.OnLink.
+func layoutSpans(gtx layout.Context, th *material.Theme, st *previewStyle, rt richText, spans []span) layout.Dimensions {
+	fg := rt.color
+	if fg == (color.NRGBA{}) {
+		fg = th.Palette.Fg
+	}
+	if rt.font.Typeface == "" {
+		rt.font.Typeface = th.Face
+	}
+	spaceWidths := map[spanStyle]int{}
+	for i := range spans {
+		if spans[i].link == "" {
+			continue
+		}
+		for {
+			e, ok := gtx.Event(pointer.Filter{Target: &spans[i], Kinds: pointer.Press})
+			if !ok {
+				break
+			}
+			if e, ok := e.(pointer.Event); ok && e.Buttons&pointer.ButtonSecondary == 0 && st.OnLink != nil {
+				st.OnLink(spans[i].link, e.Modifiers)
+			}
+		}
+	}
+
+	// Shape every word ahead of placing it.
+	var words []word
+	pendingSpace, pendingBreak := false, false
+	em := gtx.Sp(rt.size)
+	for i := range spans {
+		s := &spans[i]
+		var link *span
+		if s.link != "" {
+			link = s
+		}
+		f := styledFont(rt.font, s.style, st.Mono)
+		size, rise := rt.size, 0
+		switch {
+		case s.style&styleSup != 0:
+			size, rise = rt.size*0.75, em*35/100
+		case s.style&styleSub != 0:
+			size, rise = rt.size*0.75, -em*15/100
+		}
+		sw, ok := spaceWidths[s.style]
+		if !ok {
+			sw = measureSpace(gtx, th, st, size, f)
+			spaceWidths[s.style] = sw
+		}
+		for _, tok := range splitWords(s.text) {
+			switch tok {
+			case "\n":
+				pendingBreak, pendingSpace = true, false
+				continue
+			case " ":
+				pendingSpace = true
+				continue
+			}
+			rec := op.Record(gtx.Ops)
+			lbl := bodyLabel(th, st, size, tok)
+			lbl.MaxLines = 1
+			lbl.Font = f
+			lbl.Color = fg
+			if link != nil {
+				lbl.Color = th.Palette.ContrastBg
+			}
+			wgtx := gtx
+			wgtx.Constraints = layout.Constraints{Max: image.Pt(1<<20, gtx.Constraints.Max.Y)}
+			dims := lbl.Layout(wgtx)
+			words = append(words, word{
+				call:      rec.Stop(),
+				dims:      dims,
+				style:     s.style,
+				spaceW:    sw,
+				rise:      rise,
+				space:     pendingSpace,
+				lineBreak: pendingBreak,
+				link:      link,
+			})
+			pendingSpace, pendingBreak = false, false
+		}
+	}
+
+	// Break into lines.
+	maxW := gtx.Constraints.Max.X
+	var lines [][]word
+	var line []word
+	x := 0
+	for _, w := range words {
+		gap := 0
+		if w.space && len(line) > 0 {
+			gap = w.spaceW
+		}
+		if w.lineBreak || (len(line) > 0 && x+gap+w.dims.Size.X > maxW) {
+			lines = append(lines, line)
+			line, x, gap = nil, 0, 0
+		}
+		w.space = gap > 0
+		line = append(line, w)
+		x += gap + w.dims.Size.X
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+
+	// Place and paint.
+	y, width := 0, 0
+	for _, line := range lines {
+		ascent, descent := 0, 0
+		for _, w := range line {
+			ascent = max(ascent, w.dims.Size.Y-w.dims.Baseline+w.rise)
+			descent = max(descent, w.dims.Baseline-w.rise)
+		}
+		if len(line) == 0 {
+			ascent = em
+		}
+		x := 0
+		prevMark := false
+		var prevLink *span
+		for _, w := range line {
+			if w.space {
+				x += w.spaceW
+			}
+			wordAscent := w.dims.Size.Y - w.dims.Baseline
+			baseline := y + ascent - w.rise
+			top := baseline - wordAscent
+			r := image.Rectangle{Min: image.Pt(x, top), Max: image.Pt(x+w.dims.Size.X, top+w.dims.Size.Y)}
+			if w.style&styleCode != 0 {
+				paint.FillShape(gtx.Ops, darkenColor(th.Palette.Bg, 18), clip.Rect(r).Op())
+			}
+			if w.style&styleMark != 0 {
+				hl := r
+				if w.space && prevMark {
+					hl.Min.X -= w.spaceW // one band across highlighted phrases
+				}
+				paint.FillShape(gtx.Ops, highlightColor(th), clip.Rect(hl).Op())
+			}
+			prevMark = w.style&styleMark != 0
+			off := op.Offset(r.Min).Push(gtx.Ops)
+			w.call.Add(gtx.Ops)
+			off.Pop()
+			if w.style&styleStrike != 0 {
+				// Through the middle of lowercase letters, about a third of
+				// the ascent above the baseline.
+				mid := baseline - wordAscent/3
+				thick := max(gtx.Dp(1), 1)
+				from := r.Min.X
+				if w.space {
+					from -= w.spaceW // join struck words into one line
+				}
+				paint.FillShape(gtx.Ops, fg, clip.Rect{Min: image.Pt(from, mid), Max: image.Pt(r.Max.X, mid+thick)}.Op())
+			}
+			if w.link != nil {
+				hit := r
+				if w.space && prevLink == w.link {
+					hit.Min.X -= w.spaceW // underline across the words of one link
+				}
+				under := baseline + max(gtx.Dp(1), 1)
+				paint.FillShape(gtx.Ops, th.Palette.ContrastBg, clip.Rect{Min: image.Pt(hit.Min.X, under), Max: image.Pt(hit.Max.X, under+max(gtx.Dp(1), 1))}.Op())
+				area := clip.Rect(hit).Push(gtx.Ops)
+				pointer.CursorPointer.Add(gtx.Ops)
+				event.Op(gtx.Ops, w.link)
+				area.Pop()
+			}
+			prevLink = w.link
+			x = r.Max.X
+		}
+		width = max(width, x)
+		y += ascent + descent
+	}
+	return layout.Dimensions{Size: image.Pt(width, y)}
+}
+
+// highlightColor is the ==mark== background: highlighter yellow on light
+// themes, a dark amber that keeps light text readable on dark ones.
+func highlightColor(th *material.Theme) color.NRGBA {
+	bg := th.Palette.Bg
+	if int(bg.R)+int(bg.G)+int(bg.B) < 3*128 {
+		return color.NRGBA{R: 0x7A, G: 0x60, B: 0x10, A: 0xFF}
+	}
+	return color.NRGBA{R: 0xFF, G: 0xE8, B: 0x6B, A: 0xFF}
+}
+
+// styledFont applies the bold, italic and code attributes of style to base,
+// code in the mono typeface.
+func styledFont(base font.Font, style spanStyle, mono font.Typeface) font.Font {
+	f := base
+	if style&styleBold != 0 {
+		f.Weight = font.Bold
+	}
+	if style&styleItalic != 0 {
+		f.Style = font.Italic
+	}
+	if style&styleCode != 0 {
+		f.Typeface = mono
+	}
+	return f
+}
+
+// measureSpace returns the advance of a space in font f, measured as the
+// difference between "x x" and "xx" since a lone space shapes to nothing.
+func measureSpace(gtx layout.Context, th *material.Theme, st *previewStyle, size unit.Sp, f font.Font) int {
+	measure := func(s string) int {
+		rec := op.Record(gtx.Ops)
+		lbl := bodyLabel(th, st, size, s)
+		lbl.MaxLines = 1
+		lbl.Font = f
+		wgtx := gtx
+		wgtx.Constraints = layout.Constraints{Max: image.Pt(1<<20, gtx.Constraints.Max.Y)}
+		w := lbl.Layout(wgtx).Size.X
+		rec.Stop()
+		return w
+	}
+	return measure("x x") - measure("xx")
+}
+
+// splitWords splits s into words, with a single " " token for each run of
+// spaces and tabs and a "\n" token for each line break.
+func splitWords(s string) []string {
+	var toks []string
+	start := -1
+	for i, r := range s {
+		switch r {
+		case ' ', '\t', '\n':
+			if start >= 0 {
+				toks = append(toks, s[start:i])
+				start = -1
+			}
+			if r == '\n' {
+				toks = append(toks, "\n")
+			} else if len(toks) == 0 || toks[len(toks)-1] != " " {
+				toks = append(toks, " ")
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, s[start:])
+	}
+	return toks
+}
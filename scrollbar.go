@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const (
+	scrollbarIdle = 1500 * time.Millisecond // shown this long after activity
+	scrollbarFade = 300 * time.Millisecond
+)
+
+// scrollFade tracks when a list last scrolled, so its scrollbar can hide
+// while idle. Hovering the track brings it back for dragging.
+type scrollFade struct {
+	last   layout.Position
+	active time.Time
+}
+
+// fadingList styles state as a list whose themed scrollbar fades out after
+// scrollbarIdle without scrolling, dragging or hovering.
+func fadingList(gtx layout.Context, th *material.Theme, state *widget.List, f *scrollFade) material.ListStyle {
+	sb := &state.Scrollbar
+	if state.Position != f.last || sb.Dragging() || sb.IndicatorHovered() || sb.TrackHovered() {
+		f.last = state.Position
+		f.active = gtx.Now
+	}
+
+	alpha := float32(1)
+	switch idle := gtx.Now.Sub(f.active); {
+	case idle < scrollbarIdle:
+		gtx.Execute(op.InvalidateCmd{At: f.active.Add(scrollbarIdle)})
+	case idle < scrollbarIdle+scrollbarFade:
+		alpha = 1 - float32(idle-scrollbarIdle)/float32(scrollbarFade)
+		gtx.Execute(op.InvalidateCmd{})
+	default:
+		alpha = 0
+	}
+
+	ls := material.List(th, state)
+	ls.Indicator.Color = mulAlpha(th.Palette.ContrastBg, uint8(170*alpha))
+	ls.Indicator.HoverColor = mulAlpha(th.Palette.ContrastBg, 230)
+	ls.Indicator.MinorWidth = unit.Dp(6)
+	ls.Indicator.CornerRadius = unit.Dp(3)
+	ls.Track.Color = mulAlpha(th.Palette.Fg, uint8(14*alpha))
+	return ls
+}
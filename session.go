@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// session is what was open when Marknote last exited.
+type session struct {
+	Folder string `json:"folder"`
+	File   string `json:"file"`
+	Caret  int    `json:"caret"` // rune offset in File
+	// ReadOnly is set when Folder was opened read-only.
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// sessionPath returns the session file location next to config.json, or "".
+func sessionPath() string {
+	cfg := configPath()
+	if cfg == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(cfg), "session.json")
+}
+
+// saveSession records the open folder, file and caret position. Errors are
+// ignored: this runs on exit, when there is nowhere left to report them.
+func (a *App) saveSession() {
+	path := sessionPath()
+	if path == "" {
+		return
+	}
+	s := session{Folder: a.rootPath, File: a.currentFile, ReadOnly: a.readOnly && !a.cfg.ReadOnly}
+	if s.File != "" {
+		s.Caret, _ = a.editor.Selection()
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// restoreSession reopens the folder and file from the last session. Anything
+// deleted or moved since is skipped.
+func (a *App) restoreSession() {
+	path := sessionPath()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var s session
+	if json.Unmarshal(data, &s) != nil || s.Folder == "" {
+		return
+	}
+	if info, err := os.Stat(s.Folder); err != nil || !info.IsDir() {
+		return
+	}
+	a.openFolder(s.Folder, s.ReadOnly)
+	if s.File == "" {
+		return
+	}
+	if _, err := os.Stat(s.File); err != nil {
+		a.status = "Last open file no longer exists: " + s.File
+		return
+	}
+	a.loadFile(s.File)
+	a.revealInTree(s.File)
+	caret := min(max(s.Caret, 0), a.editor.Len())
+	a.editor.SetCaret(caret, caret)
+	a.focusEditor = true
+}
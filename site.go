@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ncruces/zenity"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	gmtext "github.com/yuin/goldmark/text"
+)
+
+// ---------------------------------------------------------------------------
+// Static site export
+//
+// A folder's notes are written out as one HTML page each, at the same
+// relative paths, with an index page listing them in tree order. Links
+// between notes are pointed at the exported pages, and the local images the
+// notes show are copied alongside: those inside the folder keep their place,
+// others go to siteImagesDir. An index.md in the folder becomes the index
+// page instead of the generated list.
+// ---------------------------------------------------------------------------
+
+const siteImagesDir = "images"
+
+// exportResult is a finished site or combined export, delivered to the
+// frame loop.
+type exportResult struct {
+	message string // reported on success
+	err     error
+}
+
+// collectNotes returns the notes under dir, in the order the tree lists them.
+func (a *App) collectNotes(dir string) []string {
+	var notes []string
+	for _, en := range a.listEntries(readTreeDir(dir, a.cfg.TreeSort.byModified())) {
+		if en.isDir {
+			notes = append(notes, a.collectNotes(en.path)...)
+		} else {
+			notes = append(notes, en.path)
+		}
+	}
+	return notes
+}
+
+// promptExportSite asks for an output folder and exports dir's notes to it
+// in a goroutine; the folder arrives through savePickCh, the result through
+// exportCh.
+func (a *App) promptExportSite(dir string) {
+	if dir == "" {
+		a.status = "Open a folder to export"
+		return
+	}
+	notes := a.collectNotes(dir)
+	if len(notes) == 0 {
+		a.status = "No notes to export in " + filepath.Base(dir)
+		return
+	}
+	md := a.md.plain
+	go func() {
+		out, err := zenity.SelectFile(zenity.Title("Export Site To"), zenity.Directory())
+		if err != nil || out == "" {
+			return
+		}
+		targets := []string{filepath.Join(out, "index.html")}
+		for _, p := range notes {
+			if rel, err := filepath.Rel(dir, p); err == nil {
+				targets = append(targets, filepath.Join(out, sitePagePath(rel)))
+			}
+		}
+		a.savePickCh <- savePick{targets: targets, write: func() {
+			a.startExport(func() exportResult {
+				pages, err := exportSite(md, dir, notes, out)
+				return exportResult{message: fmt.Sprintf("Exported %d pages to %s", pages, out), err: err}
+			})
+		}}
+		a.window.Invalidate()
+	}()
+}
+
+// startExport runs export in a goroutine; the result arrives through
+// exportCh.
+func (a *App) startExport(export func() exportResult) {
+	a.tasks++
+	go func() {
+		a.exportCh <- export()
+		a.window.Invalidate()
+	}()
+}
+
+// applyExport reports a finished export.
+func (a *App) applyExport(res exportResult) {
+	a.tasks--
+	switch {
+	case res.err != nil:
+		a.notifyError(fmt.Errorf("export failed: %w", res.err))
+	default:
+		a.notify(res.message)
+	}
+}
+
+// exportSite writes notes, all under src, to out as HTML pages plus an index,
+// and returns how many note pages were written. md renders the notes.
+func exportSite(md goldmark.Markdown, src string, notes []string, out string) (int, error) {
+	hasIndex := false
+	for _, p := range notes {
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return 0, err
+		}
+		if strings.EqualFold(rel, "index.md") {
+			hasIndex = true
+		}
+		links := strings.Repeat("../", strings.Count(filepath.ToSlash(rel), "/"))
+		page, images, err := renderSitePage(md, p, src, links)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", rel, err)
+		}
+		dst := filepath.Join(out, sitePagePath(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(dst, page, 0644); err != nil {
+			return 0, err
+		}
+		for from, to := range images {
+			// A missing image leaves a broken picture, as it would in the preview.
+			copyFile(from, filepath.Join(out, to))
+		}
+	}
+	if !hasIndex {
+		if err := os.WriteFile(filepath.Join(out, "index.html"), siteIndex(src, notes), 0644); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), nil
+}
+
+// sitePagePath is the exported page for a note at rel.
+func sitePagePath(rel string) string {
+	return strings.TrimSuffix(rel, filepath.Ext(rel)) + ".html"
+}
+
+// renderSitePage renders the note at path as a page of the site rooted at
+// src. links is the relative path from the page up to the site root. It
+// returns the images to copy, from source file to path within the site.
+func renderSitePage(md goldmark.Markdown, path, src, links string) ([]byte, map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := md.Parser().Parse(gmtext.NewReader(data))
+	setHeadingIDs(doc, data)
+	images := map[string]string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			if dest, ok := siteLinkDest(string(n.Destination)); ok {
+				n.Destination = []byte(dest)
+			}
+		case *ast.Image:
+			if dest, from, to, ok := siteImageDest(string(n.Destination), path, src, links); ok {
+				n.Destination = []byte(dest)
+				images[from] = to
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, data, doc); err != nil {
+		return nil, nil, err
+	}
+	nav := `<p><a href="` + links + `index.html">← Index</a></p>` + "\n"
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return htmlPage(title, append([]byte(nav), body.Bytes()...), ""), images, nil
+}
+
+// siteLinkDest rewrites a relative link to a .md note so it points at the
+// note's exported page, keeping any #fragment.
+func siteLinkDest(dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.EqualFold(filepath.Ext(u.Path), ".md") {
+		return "", false
+	}
+	u.Path = strings.TrimSuffix(u.Path, filepath.Ext(u.Path)) + ".html"
+	return u.String(), true
+}
+
+// siteImageDest resolves a local image of the note at notePath and picks its
+// place in the site: its own path when inside src, else siteImagesDir. It
+// returns the image's new destination, the file to copy, and where to.
+func siteImageDest(dest, notePath, src, links string) (newDest, from, to string, ok bool) {
+	u, err := url.Parse(dest)
+	if err != nil || (u.Scheme != "" && u.Scheme != "file") || u.Host != "" || u.Path == "" {
+		return "", "", "", false
+	}
+	from = filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(from) {
+		from = filepath.Join(filepath.Dir(notePath), from)
+	}
+	rel, err := filepath.Rel(src, from)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Join(siteImagesDir, filepath.Base(from))
+	}
+	newDest = (&url.URL{Path: links + filepath.ToSlash(rel)}).String()
+	return newDest, from, rel, true
+}
+
+// siteIndex is the generated index page, linking every note in order with
+// its folder path shown.
+func siteIndex(src string, notes []string) []byte {
+	var b strings.Builder
+	b.WriteString("<h1>" + html.EscapeString(filepath.Base(src)) + "</h1>\n<ul>\n")
+	for _, p := range notes {
+		rel, _ := filepath.Rel(src, p)
+		href := (&url.URL{Path: filepath.ToSlash(sitePagePath(rel))}).String()
+		label := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(label))
+	}
+	b.WriteString("</ul>\n")
+	return htmlPage(filepath.Base(src), []byte(b.String()), "")
+}
+
+// copyFile copies the file at from to to, creating to's folder.
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
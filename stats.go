@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"unicode"
+
+	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	gmtext "github.com/yuin/goldmark/text"
+)
+
+// readingWPM is the reading speed behind the estimated reading time.
+const readingWPM = 200
+
+// docStats are the figures shown by the statistics panel.
+type docStats struct {
+	words, chars, charsNoSpace int
+	sentences, paragraphs      int
+	headings, links            int
+}
+
+// computeStats measures content, parsed with md. Words, characters and
+// sentences are counted in the plain text, so markup does not inflate them;
+// the rest come from the parse.
+func computeStats(md goldmark.Markdown, content string) docStats {
+	src := []byte(content)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+
+	var st docStats
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *ast.Paragraph:
+			st.paragraphs++
+		case *ast.Heading:
+			st.headings++
+		case *ast.Link, *ast.AutoLink:
+			st.links++
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var plain strings.Builder
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		plain.WriteString(plainBlock(n, src, 0))
+		plain.WriteString("\n\n")
+	}
+	text := plain.String()
+	st.words = len(strings.Fields(text))
+	// A sentence ends at . ! or ? followed by a space, or at the end of a
+	// block, so a heading counts as one and "v1.2" does not split.
+	runes := []rune(text)
+	inSentence := false
+	for i, r := range runes {
+		if r == '\n' {
+			if inSentence && i+1 < len(runes) && runes[i+1] == '\n' {
+				st.sentences++
+				inSentence = false
+			}
+			continue
+		}
+		st.chars++
+		if !unicode.IsSpace(r) {
+			st.charsNoSpace++
+		}
+		switch {
+		case r == '.' || r == '!' || r == '?':
+			if inSentence && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+				st.sentences++
+				inSentence = false
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+	}
+	return st
+}
+
+// readingTime formats the estimated time to read words.
+func readingTime(words int) string {
+	if words == 0 {
+		return "—"
+	}
+	mins := (words + readingWPM - 1) / readingWPM
+	if mins == 1 && words < readingWPM {
+		return "under 1 min"
+	}
+	return fmt.Sprintf("%d min", mins)
+}
+
+// toggleStats shows or hides the statistics panel.
+func (a *App) toggleStats() {
+	a.showStats = !a.showStats
+	a.statsText = ""
+	a.window.Invalidate()
+}
+
+// layoutStats draws the statistics for the open note in a small card over a
+// scrim, recomputing them whenever the text changes. Clicking the scrim
+// closes it.
+func (a *App) layoutStats(gtx layout.Context) layout.Dimensions {
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &a.showStats, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if _, ok := e.(pointer.Event); ok {
+			a.showStats = false
+			return layout.Dimensions{}
+		}
+	}
+	for {
+		if _, ok := gtx.Event(pointer.Filter{Target: &a.stats, Kinds: pointer.Press}); !ok {
+			break
+		}
+	}
+
+	if text := a.docText(); text != a.statsText || a.statsText == "" {
+		a.statsText = text
+		a.stats = computeStats(a.md.plain, text)
+	}
+	st := a.stats
+	rows := [][2]string{
+		{"Words", fmt.Sprint(st.words)},
+		{"Characters", fmt.Sprint(st.chars)},
+		{"Characters (no spaces)", fmt.Sprint(st.charsNoSpace)},
+		{"Sentences", fmt.Sprint(st.sentences)},
+		{"Paragraphs", fmt.Sprint(st.paragraphs)},
+		{"Headings", fmt.Sprint(st.headings)},
+		{"Links", fmt.Sprint(st.links)},
+		{"Reading time", readingTime(st.words)},
+	}
+
+	paint.FillShape(gtx.Ops, color.NRGBA{A: 150}, clip.Rect{Max: gtx.Constraints.Max}.Op())
+	scrim := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &a.showStats)
+	scrim.Pop()
+
+	return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min.X = min(gtx.Dp(320), gtx.Constraints.Max.X)
+		gtx.Constraints.Max.X = gtx.Constraints.Min.X
+		return withBackground(gtx, previewBg(a.th.Palette.Bg), unit.Dp(16), func(gtx layout.Context) layout.Dimensions {
+			// Swallow clicks on the card so they do not reach the scrim.
+			defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+			event.Op(gtx.Ops, &a.stats)
+
+			children := []layout.FlexChild{
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					title := material.H6(a.th, "Document Statistics")
+					return layout.Inset{Bottom: unit.Dp(10)}.Layout(gtx, title.Layout)
+				}),
+			}
+			for _, row := range rows {
+				children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Inset{Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+							layout.Flexed(1, material.Body2(a.th, row[0]).Layout),
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								lbl := material.Body2(a.th, row[1])
+								lbl.Font.Weight = font.Bold
+								return lbl.Layout(gtx)
+							}),
+						)
+					})
+				}))
+			}
+			dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+			dims.Size = image.Pt(gtx.Constraints.Max.X, dims.Size.Y)
+			return dims
+		})
+	})
+}
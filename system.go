@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/layout"
+)
+
+// openWithSystem opens path (a file or URL) with the OS default handler.
+func openWithSystem(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	case "darwin":
+		cmd = exec.Command("open", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the launcher in the background; we don't care how it exits.
+	go cmd.Wait()
+	return nil
+}
+
+// writeClipboard puts text on the system clipboard. Gio only exchanges plain
+// text with the OS clipboard, so there is no way to offer other MIME types.
+func writeClipboard(gtx layout.Context, text string) {
+	gtx.Execute(clipboard.WriteCmd{
+		Type: "application/text",
+		Data: io.NopCloser(strings.NewReader(text)),
+	})
+}
+
+var errNoClipboardImage = errors.New("clipboard does not contain an image")
+
+// readClipboardImage returns the clipboard image as PNG bytes. Gio's clipboard
+// is text-only, so this shells out to the platform's clipboard tools:
+// PowerShell on Windows, osascript on macOS, and wl-paste or xclip on Linux.
+func readClipboardImage() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; "+
+				"$i = [System.Windows.Forms.Clipboard]::GetImage(); if ($i -eq $null) { exit 1 }; "+
+				"$m = New-Object System.IO.MemoryStream; $i.Save($m, [System.Drawing.Imaging.ImageFormat]::Png); "+
+				"$o = [Console]::OpenStandardOutput(); $o.Write($m.ToArray(), 0, $m.Length); $o.Flush()")
+	case "darwin":
+		out, err := exec.Command("osascript", "-e", "the clipboard as «class PNGf»").Output()
+		if err != nil {
+			return nil, errNoClipboardImage
+		}
+		// Output looks like «data PNGf89504E47…»; decode the hex payload.
+		s := strings.TrimSpace(string(out))
+		i := strings.Index(s, "PNGf")
+		j := strings.LastIndex(s, "»")
+		if i < 0 || j < i {
+			return nil, errNoClipboardImage
+		}
+		return hex.DecodeString(s[i+len("PNGf") : j])
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-paste", "--no-newline", "--type", "image/png")
+		} else {
+			cmd = exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-out")
+		}
+	}
+	out, err := cmd.Output()
+	if err != nil || !bytes.HasPrefix(out, []byte("\x89PNG")) {
+		return nil, errNoClipboardImage
+	}
+	return out, nil
+}
+
+var errNoClipboardHTML = errors.New("clipboard does not contain HTML")
+
+// readClipboardHTML returns the clipboard's HTML flavour, which browsers and
+// word processors put beside the plain text when copying. Like
+// readClipboardImage it shells out to the platform's clipboard tools.
+func readClipboardHTML() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command",
+			"[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard -TextFormatType Html -Raw")
+	case "darwin":
+		out, err := exec.Command("osascript", "-e", "the clipboard as «class HTML»").Output()
+		if err != nil {
+			return "", errNoClipboardHTML
+		}
+		// Output looks like «data HTML3C6D657461…»; decode the hex payload.
+		s := strings.TrimSpace(string(out))
+		i := strings.Index(s, "HTML")
+		j := strings.LastIndex(s, "»")
+		if i < 0 || j < i {
+			return "", errNoClipboardHTML
+		}
+		data, err := hex.DecodeString(s[i+len("HTML") : j])
+		if err != nil {
+			return "", errNoClipboardHTML
+		}
+		return string(data), nil
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-paste", "--no-newline", "--type", "text/html")
+		} else {
+			cmd = exec.Command("xclip", "-selection", "clipboard", "-target", "text/html", "-out")
+		}
+	}
+	out, err := cmd.Output()
+	s := htmlFragment(string(out))
+	if err != nil || strings.TrimSpace(s) == "" {
+		return "", errNoClipboardHTML
+	}
+	return s, nil
+}
+
+// htmlFragment strips the Windows CF_HTML header ("Version:0.9
+// StartHTML:…") and the surrounding document, keeping the copied fragment.
+func htmlFragment(s string) string {
+	if _, after, ok := strings.Cut(s, "<!--StartFragment-->"); ok {
+		s, _, _ = strings.Cut(after, "<!--EndFragment-->")
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templatesDir returns the folder holding user note templates (*.md files
+// next to the config file), or "" if the config directory is unknown.
+func templatesDir() string {
+	path := configPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "templates")
+}
+
+// listTemplates returns the names (without extension) of available templates.
+func listTemplates() []string {
+	dir := templatesDir()
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || strings.ToLower(filepath.Ext(e.Name())) != ".md" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readTemplate returns the raw content of the named template.
+func readTemplate(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(templatesDir(), name+".md"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// expandTemplate substitutes {{title}}, {{filename}} and {{date}} for a note
+// being created at path.
+func (a *App) expandTemplate(tmpl, path string, now time.Time) string {
+	dateLayout := a.cfg.DateFormat
+	if dateLayout == "" {
+		dateLayout = time.DateOnly
+	}
+	base := filepath.Base(path)
+	return strings.NewReplacer(
+		"{{title}}", strings.TrimSuffix(base, filepath.Ext(base)),
+		"{{filename}}", base,
+		"{{date}}", now.Format(dateLayout),
+	).Replace(tmpl)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"image/color"
+	"slices"
+	"strings"
 
 	"gioui.org/widget/material"
 )
@@ -14,6 +16,24 @@ const (
 	themeSepia
 )
 
+// themeNames are the Config.Theme values, indexed by variant.
+var themeNames = []string{themeLight: "light", themeDark: "dark", themeSepia: "sepia"}
+
+// themeNamed returns the variant Config.Theme names; anything else is light.
+func themeNamed(name string) themeVariant {
+	if i := slices.Index(themeNames, strings.ToLower(name)); i >= 0 {
+		return themeVariant(i)
+	}
+	return themeLight
+}
+
+// setTheme applies t and saves it as Config.Theme.
+func (a *App) setTheme(t themeVariant) {
+	a.applyTheme(t)
+	a.cfg.Theme = themeNames[t]
+	a.saveConfig()
+}
+
 // applyTheme switches the active Gio palette.
 func (a *App) applyTheme(t themeVariant) {
 	switch t {
@@ -44,3 +64,17 @@ func sepiaPalette() material.Palette {
 		ContrastFg: color.NRGBA{R: 255, G: 248, B: 235, A: 255},
 	}
 }
+
+// codeScheme colours preview code blocks independently of the app theme.
+type codeScheme struct {
+	bg, fg color.NRGBA
+}
+
+// codeSchemes are the bundled Config.CodeTheme choices.
+var codeSchemes = map[string]*codeScheme{
+	"github":          {bg: color.NRGBA{R: 0xF6, G: 0xF8, B: 0xFA, A: 0xFF}, fg: color.NRGBA{R: 0x24, G: 0x29, B: 0x2F, A: 0xFF}},
+	"monokai":         {bg: color.NRGBA{R: 0x27, G: 0x28, B: 0x22, A: 0xFF}, fg: color.NRGBA{R: 0xF8, G: 0xF8, B: 0xF2, A: 0xFF}},
+	"dracula":         {bg: color.NRGBA{R: 0x28, G: 0x2A, B: 0x36, A: 0xFF}, fg: color.NRGBA{R: 0xF8, G: 0xF8, B: 0xF2, A: 0xFF}},
+	"solarized-light": {bg: color.NRGBA{R: 0xFD, G: 0xF6, B: 0xE3, A: 0xFF}, fg: color.NRGBA{R: 0x65, G: 0x7B, B: 0x83, A: 0xFF}},
+	"solarized-dark":  {bg: color.NRGBA{R: 0x00, G: 0x2B, B: 0x36, A: 0xFF}, fg: color.NRGBA{R: 0x83, G: 0x94, B: 0x96, A: 0xFF}},
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+const (
+	toastDuration = 4 * time.Second
+	toastFade     = 400 * time.Millisecond
+	maxToasts     = 4
+)
+
+// toast is a transient message stacked in the bottom-right corner.
+type toast struct {
+	text    string
+	isErr   bool
+	expires time.Time
+}
+
+// notify shows text as a toast and in the status bar.
+func (a *App) notify(text string) {
+	a.pushToast(toast{text: text})
+	a.status = text
+}
+
+// notifyError reports a non-blocking error as a toast and in the status bar.
+func (a *App) notifyError(err error) {
+	a.pushToast(toast{text: err.Error(), isErr: true})
+	a.status = "Error: " + err.Error()
+}
+
+func (a *App) pushToast(t toast) {
+	t.expires = time.Now().Add(toastDuration)
+	a.toasts = append(a.toasts, t)
+	if len(a.toasts) > maxToasts {
+		a.toasts = a.toasts[len(a.toasts)-maxToasts:]
+	}
+	a.window.Invalidate()
+}
+
+// layoutToasts draws live toasts above the status bar, newest at the bottom,
+// fading each out over its last moments, and drops expired ones.
+func (a *App) layoutToasts(gtx layout.Context) {
+	now := gtx.Now
+	live := a.toasts[:0]
+	for _, t := range a.toasts {
+		if now.Before(t.expires) {
+			live = append(live, t)
+		}
+	}
+	a.toasts = live
+	if len(a.toasts) == 0 {
+		return
+	}
+
+	margin := gtx.Dp(12)
+	y := gtx.Constraints.Max.Y - gtx.Dp(24) - margin // clear of the status bar
+	fading := false
+	for i := len(a.toasts) - 1; i >= 0; i-- {
+		t := a.toasts[i]
+		alpha := 1.0
+		if left := t.expires.Sub(now); left < toastFade {
+			alpha = float64(left) / float64(toastFade)
+			fading = true
+		}
+
+		bg := color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 235}
+		if t.isErr {
+			bg = color.NRGBA{R: 0xB0, G: 0x30, B: 0x30, A: 235}
+		}
+		bg.A = uint8(float64(bg.A) * alpha)
+		fg := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: uint8(255 * alpha)}
+
+		rec := op.Record(gtx.Ops)
+		lgtx := gtx
+		lgtx.Constraints = layout.Constraints{Max: image.Pt(gtx.Dp(360), gtx.Constraints.Max.Y)}
+		dims := layout.UniformInset(unit.Dp(8)).Layout(lgtx, func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Label(a.th, unit.Sp(13), t.text)
+			lbl.Color = fg
+			lbl.MaxLines = 3
+			return lbl.Layout(gtx)
+		})
+		call := rec.Stop()
+
+		y -= dims.Size.Y
+		pos := image.Pt(gtx.Constraints.Max.X-margin-dims.Size.X, y)
+		off := op.Offset(pos).Push(gtx.Ops)
+		paint.FillShape(gtx.Ops, bg, clip.UniformRRect(image.Rectangle{Max: dims.Size}, gtx.Dp(4)).Op(gtx.Ops))
+		call.Add(gtx.Ops)
+		off.Pop()
+		y -= gtx.Dp(6)
+	}
+
+	if fading {
+		gtx.Execute(op.InvalidateCmd{})
+	} else {
+		next := a.toasts[0].expires
+		for _, t := range a.toasts {
+			if t.expires.Before(next) {
+				next = t.expires
+			}
+		}
+		gtx.Execute(op.InvalidateCmd{At: next.Add(-toastFade)})
+	}
+}
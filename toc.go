@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	gmtext "github.com/yuin/goldmark/text"
+)
+
+// ---------------------------------------------------------------------------
+// Table of contents
+//
+// Ctrl+Shift+T writes a nested list of links to the document's headings
+// between tocStart and tocEnd comments. Re-running it rewrites that region;
+// otherwise a "[TOC]" line, or the caret, marks where it goes. Anchors use
+// GitHub's heading slugs, which the preview also follows.
+// ---------------------------------------------------------------------------
+
+const (
+	tocStart  = "<!-- toc -->"
+	tocEnd    = "<!-- /toc -->"
+	tocMarker = "[TOC]"
+)
+
+// slugify turns heading text into a GitHub-style anchor: lower case, with
+// punctuation dropped and spaces turned into hyphens.
+func slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// slugger hands out unique slugs within one document, suffixing repeats
+// with -1, -2 and so on.
+type slugger map[string]int
+
+func (s slugger) slug(text string) string {
+	base := slugify(text)
+	slug := base
+	for n := s[base]; ; n++ {
+		if n > 0 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		if _, taken := s[slug]; !taken {
+			s[base] = n + 1
+			s[slug] = 1
+			return slug
+		}
+	}
+}
+
+// setHeadingIDs gives doc's top-level headings the slugs the preview and
+// buildTOC give them, as id attributes for the HTML renderer.
+func setHeadingIDs(doc ast.Node, src []byte) {
+	slugs := slugger{}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok {
+			h.SetAttributeString("id", []byte(slugs.slug(extractText(h, src))))
+		}
+	}
+}
+
+// buildTOC returns a markdown list linking to every heading in content,
+// parsed with md, indented by level relative to the shallowest heading, or
+// "" if there are none.
+func buildTOC(md goldmark.Markdown, content string) string {
+	src := []byte(content)
+	doc := md.Parser().Parse(gmtext.NewReader(src))
+	type entry struct {
+		level      int
+		text, slug string
+	}
+	var entries []entry
+	slugs := slugger{}
+	minLevel := 7
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		text := extractText(h, src)
+		entries = append(entries, entry{h.Level, text, slugs.slug(text)})
+		minLevel = min(minLevel, h.Level)
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		text := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(e.text)
+		fmt.Fprintf(&b, "%s- [%s](#%s)\n", strings.Repeat("  ", e.level-minLevel), text, e.slug)
+	}
+	return b.String()
+}
+
+// insertTOC writes or refreshes the table of contents.
+func (a *App) insertTOC() {
+	if a.currentFile == "" || a.denyReadOnly() {
+		return
+	}
+	a.unfoldAll()
+	text := a.editor.Text()
+	toc := buildTOC(a.md.plain, text)
+	if toc == "" {
+		a.status = "No headings for a table of contents"
+		return
+	}
+	region := tocStart + "\n" + toc + tocEnd
+	runeAt := func(i int) int { return utf8.RuneCountInString(text[:i]) }
+
+	if s := strings.Index(text, tocStart); s >= 0 {
+		if e := strings.Index(text[s:], tocEnd); e >= 0 {
+			a.replaceRange(runeAt(s), runeAt(s+e+len(tocEnd)), region)
+			a.status = "Updated table of contents"
+			return
+		}
+	}
+	off := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.TrimSpace(line) == tocMarker {
+			end := off + len(strings.TrimRight(line, "\r\n"))
+			a.replaceRange(runeAt(off), runeAt(end), region)
+			a.status = "Inserted table of contents"
+			return
+		}
+		off += len(line)
+	}
+	// At the caret, on lines of its own.
+	runes := []rune(text)
+	caret, _ := a.orderedSelection()
+	if caret > lineStart(runes, caret) {
+		region = "\n" + region
+	}
+	a.editor.Insert(region + "\n")
+	a.status = "Inserted table of contents"
+}
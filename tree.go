@@ -1,17 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"gioui.org/font"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
@@ -25,11 +30,79 @@ type treeNode struct {
 	name  string
 	isDir bool
 	depth int
+
+	// Placeholder rows stand in for a folder's children, under the folder's
+	// own path: loading while its listing is read, or more > 0 when that many
+	// entries are not yet paged in.
+	loading bool
+	more    int
+}
+
+// placeholder reports whether n stands in for rows rather than being one.
+func (n treeNode) placeholder() bool {
+	return n.loading || n.more > 0
+}
+
+// treePageSize is how many entries of a folder get rows at a time; the rest
+// are paged in as the list scrolls down to them.
+const treePageSize = 500
+
+// treeEntry is one child of a folder as read from disk.
+type treeEntry struct {
+	path  string
+	isDir bool
+	mtime time.Time // zero unless the listing was read for a modified sort
+}
+
+// dirListing is the cached, filtered and sorted listing of one folder.
+// Stale listings are still shown while they are read again.
+type dirListing struct {
+	entries []treeEntry
+	mtimes  bool // entries carry modification times
+	stale   bool
+}
+
+// treeListing is a folder read in the background, delivered to the frame
+// loop.
+type treeListing struct {
+	dir     string
+	entries []treeEntry
+	mtimes  bool
+	gen     int // FileTree.gen when the read started
 }
 
 // rowTag is a unique pointer-event tag per tree row.
 type rowTag struct{ idx int }
 
+// treeSortOrder selects how listEntries orders entries within a folder.
+type treeSortOrder int
+
+const (
+	sortNameAsc treeSortOrder = iota
+	sortNameDesc
+	sortModifiedNewest
+	sortModifiedOldest
+	numTreeSortOrders
+)
+
+func (s treeSortOrder) label() string {
+	switch s {
+	case sortNameDesc:
+		return "Name Z–A"
+	case sortModifiedNewest:
+		return "Newest"
+	case sortModifiedOldest:
+		return "Oldest"
+	default:
+		return "Name A–Z"
+	}
+}
+
+// byModified reports whether s sorts by modification time.
+func (s treeSortOrder) byModified() bool {
+	return s == sortModifiedNewest || s == sortModifiedOldest
+}
+
 // FileTree renders the folder/file hierarchy as a scrollable flat list.
 type FileTree struct {
 	app      *App
@@ -37,57 +110,426 @@ type FileTree struct {
 	visible  []treeNode
 
 	list       widget.List
+	fade       scrollFade
 	rowTags    []rowTag
 	hoveredIdx int // index of hovered row, -1 if none
+
+	// Keyboard focus tag; arrow keys move selectedPath through the rows
+	focusTag struct{}
+	pressAt  image.Point // last press in the list area
+	menu     *treeMenu   // open context menu, or nil
+	pinBtns  map[string]*widget.Clickable
+
+	// Folder expand/collapse animation in progress, if any
+	anim *treeAnim
+
+	// Folder listings are read in goroutines and cached here until Refresh
+	// marks them stale, so expanding a huge folder never blocks a frame.
+	listings map[string]*dirListing
+	loading  map[string]bool
+	gen      int            // bumped by Refresh; older reads come back stale
+	shown    map[string]int // entries paged in per folder, beyond treePageSize
+	reveal   string         // path to scroll to once its folders have loaded
+	grow     string         // folder whose next page to add after layout
+
+	// Header controls
+	filter       widget.Editor
+	query        string // lower-cased filter text applied by the last rebuild
+	btnSort      widget.Clickable
+	btnDirsFirst widget.Clickable
+	btnCollapse  widget.Clickable
 }
 
 func newFileTree(a *App) *FileTree {
 	ft := &FileTree{
 		app:        a,
 		expanded:   make(map[string]bool),
+		listings:   make(map[string]*dirListing),
+		loading:    make(map[string]bool),
+		shown:      make(map[string]int),
 		hoveredIdx: -1,
 	}
 	ft.list.Axis = layout.Vertical
+	ft.filter.SingleLine = true
 	return ft
 }
 
 // rebuild recomputes the visible flat list from the filesystem.
 func (ft *FileTree) rebuild() {
 	ft.visible = nil
+	ft.query = strings.ToLower(strings.TrimSpace(ft.filter.Text()))
 	if ft.app.rootPath == "" {
 		return
 	}
 	ft.appendChildren(ft.app.rootPath, 0)
 }
 
-func (ft *FileTree) appendChildren(dir string, depth int) {
-	children := ft.app.listDir(dir)
-	for _, p := range children {
-		info, err := os.Stat(p)
-		if err != nil {
-			continue
-		}
+// appendChildren appends the rows under dir and reports whether any were
+// added. While a filter is active every folder is walked regardless of
+// expanded state, and only matching rows plus their ancestor folders are kept.
+// A folder still being read gets a loading row, and one longer than its page
+// ends in a row counting the entries left.
+func (ft *FileTree) appendChildren(dir string, depth int) bool {
+	l := ft.listing(dir)
+	if l == nil {
+		ft.visible = append(ft.visible, treeNode{path: dir, depth: depth, loading: true})
+		return true
+	}
+	entries, more := l.entries, 0
+	if n := ft.pageSize(dir); !ft.filtering() && len(entries) > n {
+		entries, more = entries[:n], len(entries)-n
+	}
+
+	found := false
+	for _, en := range entries {
+		name := filepath.Base(en.path)
+		matches := ft.query == "" || strings.Contains(strings.ToLower(name), ft.query)
+
+		mark := len(ft.visible)
 		ft.visible = append(ft.visible, treeNode{
-			path:  p,
-			name:  filepath.Base(p),
-			isDir: info.IsDir(),
+			path:  en.path,
+			name:  name,
+			isDir: en.isDir,
 			depth: depth,
 		})
-		if info.IsDir() && ft.expanded[p] {
-			ft.appendChildren(p, depth+1)
+		descendants := false
+		if en.isDir && (ft.expanded[en.path] || ft.query != "") {
+			descendants = ft.appendChildren(en.path, depth+1)
 		}
+		if !matches && !descendants {
+			ft.visible = ft.visible[:mark]
+			continue
+		}
+		found = true
+	}
+	if more > 0 {
+		ft.visible = append(ft.visible, treeNode{path: dir, depth: depth, more: more})
+	}
+	return found
+}
+
+// pageSize returns how many of dir's entries get rows.
+func (ft *FileTree) pageSize(dir string) int {
+	return treePageSize + ft.shown[dir]
+}
+
+// showMore pages in the next treePageSize entries of dir.
+func (ft *FileTree) showMore(dir string) {
+	ft.shown[dir] += treePageSize
+	ft.rebuild()
+}
+
+// listing returns the cached listing of dir, or nil if it has not been read
+// yet, starting a background read when it is missing or stale.
+func (ft *FileTree) listing(dir string) *dirListing {
+	l := ft.listings[dir]
+	if (l == nil || l.stale) && !ft.loading[dir] {
+		ft.load(dir)
 	}
+	return l
+}
+
+// load reads dir in a goroutine; the result arrives through treeCh and is
+// picked up by drainListings.
+func (ft *FileTree) load(dir string) {
+	a := ft.app
+	ft.loading[dir] = true
+	mtimes, gen := a.cfg.TreeSort.byModified(), ft.gen
+	a.tasks++
+	go func() {
+		a.treeCh <- treeListing{dir: dir, entries: readTreeDir(dir, mtimes), mtimes: mtimes, gen: gen}
+		a.window.Invalidate()
+	}()
 }
 
-// Reset clears expanded state and rebuilds.
+// drainListings caches every folder read that has finished and rebuilds the
+// rows once. Reads started before the last Refresh are kept but read again.
+func (ft *FileTree) drainListings() {
+	applied := false
+	for {
+		select {
+		case res := <-ft.app.treeCh:
+			ft.app.tasks--
+			delete(ft.loading, res.dir)
+			if !ft.contains(res.dir) {
+				continue // another folder was opened meanwhile
+			}
+			ft.listings[res.dir] = &dirListing{
+				entries: ft.app.listEntries(res.entries),
+				mtimes:  res.mtimes,
+				stale:   res.gen != ft.gen,
+			}
+			applied = true
+		default:
+			if !applied {
+				return
+			}
+			if ft.reveal != "" {
+				ft.scrollToReveal()
+			} else {
+				ft.rebuild()
+			}
+			return
+		}
+	}
+}
+
+// resort reorders the cached listings after the sort settings change. A
+// modified-time order needs times the listing may lack, so those are read
+// again.
+func (ft *FileTree) resort() {
+	byModified := ft.app.cfg.TreeSort.byModified()
+	for _, l := range ft.listings {
+		l.entries = ft.app.listEntries(l.entries)
+		if byModified && !l.mtimes {
+			l.stale = true
+		}
+	}
+	ft.rebuild()
+}
+
+// contains reports whether path is the root folder or inside it.
+func (ft *FileTree) contains(path string) bool {
+	root := ft.app.rootPath
+	rel, err := filepath.Rel(root, path)
+	return root != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Reveal expands every folder between the root and path, so path's row is
+// shown, and scrolls the list to it. Folders are expanded themselves too.
+// Folders not read yet are waited for.
+func (ft *FileTree) Reveal(path string) {
+	if !ft.contains(path) {
+		return
+	}
+	if rel, _ := filepath.Rel(ft.app.rootPath, path); rel != "." {
+		dir := ft.app.rootPath
+		parts := strings.Split(rel, string(filepath.Separator))
+		for _, part := range parts[:len(parts)-1] {
+			dir = filepath.Join(dir, part)
+			ft.expanded[dir] = true
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			ft.expanded[path] = true
+		}
+	}
+	ft.saveExpanded()
+	ft.reveal = path
+	ft.list.Position.First = 0
+	ft.list.Position.Offset = 0
+	ft.scrollToReveal()
+}
+
+// revealInTree selects path in the tree, expanding the folders leading to it
+// and scrolling its row into view. Entry points that open a file without a
+// click on its row use it so the tree follows along.
+func (a *App) revealInTree(path string) {
+	a.selectedPath = path
+	a.fileTree.Reveal(path)
+}
+
+// revealCurrentFile reveals the open file in the tree, leaving focus mode
+// so the tree shows, for when it has been scrolled or collapsed away.
+func (a *App) revealCurrentFile(gtx layout.Context) {
+	p := a.currentFile
+	switch {
+	case p == "":
+		a.status = "No file open"
+		return
+	case !a.fileTree.contains(p):
+		a.status = filepath.Base(p) + " is outside the open folder"
+		return
+	case a.ignored(p, false):
+		a.status = filepath.Base(p) + " is hidden by the ignore patterns"
+		return
+	}
+	if a.focusMode {
+		a.toggleFocusMode(gtx)
+	}
+	a.revealInTree(p)
+}
+
+// scrollToReveal pages in the rows leading to ft.reveal, rebuilds, and
+// scrolls to its row once there is one. It stops waiting when no folder is
+// being read.
+func (ft *FileTree) scrollToReveal() {
+	for child := ft.reveal; child != ft.app.rootPath; child = filepath.Dir(child) {
+		dir := filepath.Dir(child)
+		l := ft.listings[dir]
+		if l == nil {
+			continue
+		}
+		for i, en := range l.entries {
+			if en.path == child {
+				if i >= ft.pageSize(dir) {
+					ft.shown[dir] = (i / treePageSize) * treePageSize
+				}
+				break
+			}
+		}
+	}
+	ft.rebuild()
+	for i, n := range ft.visible {
+		if n.path == ft.reveal && !n.placeholder() {
+			ft.list.Position.First = i
+			ft.list.Position.Offset = 0
+			ft.reveal = ""
+			return
+		}
+	}
+	if len(ft.loading) == 0 {
+		ft.reveal = ""
+	}
+}
+
+// treeAnimDuration is how long a folder takes to slide open or closed.
+const treeAnimDuration = 150 * time.Millisecond
+
+// treeAnim tracks a folder whose child rows are sliding in or out.
+type treeAnim struct {
+	path      string
+	expanding bool
+	start     time.Time
+}
+
+// toggle expands or collapses the folder at path, animating the change
+// unless animation is disabled or a filter is active.
+func (ft *FileTree) toggle(path string) {
+	expanding := !ft.expanded[path]
+	if ft.anim != nil {
+		ft.finishAnim()
+		expanding = !ft.expanded[path]
+	}
+	defer ft.saveExpanded()
+	if !ft.app.cfg.AnimateTree || ft.filtering() {
+		ft.expanded[path] = expanding
+		ft.rebuild()
+		return
+	}
+	// Collapsing folders stay expanded until their rows have slid away.
+	if expanding {
+		ft.expanded[path] = true
+		ft.rebuild()
+	}
+	ft.anim = &treeAnim{path: path, expanding: expanding, start: time.Now()}
+	// With no child rows there is nothing to slide.
+	if !slices.ContainsFunc(ft.visible, func(n treeNode) bool { return isUnder(n.path, path) }) {
+		ft.finishAnim()
+	}
+}
+
+// isUnder reports whether p is inside the folder dir.
+func isUnder(p, dir string) bool {
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// finishAnim jumps the running animation to its end state.
+func (ft *FileTree) finishAnim() {
+	if !ft.anim.expanding {
+		ft.expanded[ft.anim.path] = false
+		ft.rebuild()
+	}
+	ft.anim = nil
+}
+
+// animatedHeight returns the height to draw node's row at, which is less
+// than rowH while the row belongs to a folder that is sliding open or shut.
+func (ft *FileTree) animatedHeight(gtx layout.Context, node treeNode, rowH int) int {
+	an := ft.anim
+	if an == nil || !isUnder(node.path, an.path) {
+		return rowH
+	}
+	// The clock starts in toggle, which may run a moment after gtx.Now.
+	progress := max(0, min(float32(gtx.Now.Sub(an.start))/float32(treeAnimDuration), 1))
+	gtx.Execute(op.InvalidateCmd{})
+	if !an.expanding {
+		progress = 1 - progress
+	}
+	return int(float32(rowH) * progress)
+}
+
+// filtering reports whether the filter box currently narrows the tree.
+func (ft *FileTree) filtering() bool {
+	return ft.query != ""
+}
+
+// Reset clears expanded state and cached listings, and rebuilds.
 func (ft *FileTree) Reset() {
-	ft.expanded = make(map[string]bool)
+	ft.reset(make(map[string]bool))
+}
+
+// Restore is Reset for a newly opened folder, but with Config.RememberExpanded
+// the folders expanded when it was last open are expanded again.
+func (ft *FileTree) Restore() {
+	ft.reset(ft.app.savedExpanded())
+}
+
+func (ft *FileTree) reset(expanded map[string]bool) {
+	ft.expanded = expanded
+	ft.listings = make(map[string]*dirListing)
+	ft.shown = make(map[string]int)
+	ft.reveal = ""
+	ft.gen++
 	ft.hoveredIdx = -1
+	ft.filter.SetText("")
 	ft.rebuild()
 }
 
-// Refresh rebuilds without clearing expanded state.
+// saveExpanded records the expanded folders in Config.ExpandedFolders and
+// saves the config if they changed. A folder sliding shut counts as closed.
+func (ft *FileTree) saveExpanded() {
+	a := ft.app
+	if !a.cfg.RememberExpanded || a.rootPath == "" {
+		return
+	}
+	var keys []string
+	for p, open := range ft.expanded {
+		if !open || (ft.anim != nil && ft.anim.path == p && !ft.anim.expanding) {
+			continue
+		}
+		if key, ok := a.folderKey(p); ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	if slices.Equal(keys, a.cfg.ExpandedFolders[a.rootPath]) {
+		return
+	}
+	if a.cfg.ExpandedFolders == nil {
+		a.cfg.ExpandedFolders = make(map[string][]string)
+	}
+	if len(keys) == 0 {
+		delete(a.cfg.ExpandedFolders, a.rootPath)
+	} else {
+		a.cfg.ExpandedFolders[a.rootPath] = keys
+	}
+	a.saveConfig()
+}
+
+// savedExpanded returns the open folder's remembered expanded folders that
+// still exist. Those that do not are dropped from the config with the next
+// saveExpanded.
+func (a *App) savedExpanded() map[string]bool {
+	expanded := make(map[string]bool)
+	if !a.cfg.RememberExpanded {
+		return expanded
+	}
+	for _, key := range a.cfg.ExpandedFolders[a.rootPath] {
+		p := filepath.Join(a.rootPath, filepath.FromSlash(key))
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			expanded[p] = true
+		}
+	}
+	return expanded
+}
+
+// Refresh reads every listed folder again, showing the old rows until the
+// new ones arrive. Expanded state is kept.
 func (ft *FileTree) Refresh() {
+	ft.gen++
+	for _, l := range ft.listings {
+		l.stale = true
+	}
 	ft.rebuild()
 }
 
@@ -96,6 +538,92 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 	treeBg := darkenColor(th.Palette.Bg, 8)
 	paint.FillShape(gtx.Ops, treeBg, clip.Rect{Max: gtx.Constraints.Max}.Op())
 
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return ft.layoutHeader(gtx, th)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return ft.layoutPins(gtx, th)
+		}),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return ft.layoutRows(gtx, th)
+		}),
+	)
+}
+
+// layoutHeader draws the filter box and sort controls above the tree rows.
+func (ft *FileTree) layoutHeader(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	cfg := &ft.app.cfg
+	for {
+		ev, ok := ft.filter.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.ChangeEvent); ok {
+			ft.hoveredIdx = -1
+			ft.rebuild()
+		}
+	}
+	if ft.btnSort.Clicked(gtx) {
+		cfg.TreeSort = (cfg.TreeSort + 1) % numTreeSortOrders
+		ft.app.saveConfig()
+		ft.resort()
+	}
+	if ft.btnDirsFirst.Clicked(gtx) {
+		cfg.DirsFirst = !cfg.DirsFirst
+		ft.app.saveConfig()
+		ft.resort()
+	}
+	if ft.btnCollapse.Clicked(gtx) {
+		ft.Reset()
+		ft.saveExpanded()
+	}
+
+	dirsLabel := "Dirs mixed"
+	if cfg.DirsFirst {
+		dirsLabel = "Dirs first"
+	}
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return withBackground(gtx, th.Palette.Bg, unit.Dp(4), func(gtx layout.Context) layout.Dimensions {
+					ed := material.Editor(th, &ft.filter, "Filter…")
+					ed.TextSize = unit.Sp(12)
+					return ed.Layout(gtx)
+				})
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Dimensions{Size: image.Pt(1, gtx.Dp(4))}
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return treeHeaderButton(th, &ft.btnSort, cfg.TreeSort.label()).Layout(gtx)
+					}),
+					layout.Rigid(spacer(4)),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return treeHeaderButton(th, &ft.btnDirsFirst, dirsLabel).Layout(gtx)
+					}),
+					layout.Rigid(spacer(4)),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return treeHeaderButton(th, &ft.btnCollapse, "Collapse").Layout(gtx)
+					}),
+				)
+			}),
+		)
+	})
+}
+
+// treeHeaderButton is a compact button sized for the tree header.
+func treeHeaderButton(th *material.Theme, c *widget.Clickable, txt string) material.ButtonStyle {
+	btn := material.Button(th, c, txt)
+	btn.TextSize = unit.Sp(11)
+	btn.Inset = layout.Inset{Top: unit.Dp(3), Bottom: unit.Dp(3), Left: unit.Dp(6), Right: unit.Dp(6)}
+	return btn
+}
+
+// layoutRows draws the scrollable list of tree rows.
+func (ft *FileTree) layoutRows(gtx layout.Context, th *material.Theme) layout.Dimensions {
 	n := len(ft.visible)
 
 	// Grow per-row tag slice as the list gains entries.
@@ -105,7 +633,38 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 
 	rowH := gtx.Dp(28)
 
-	return material.List(th, &ft.list).Layout(gtx, n, func(gtx layout.Context, i int) layout.Dimensions {
+	// End a finished animation before laying out, so the list isn't rebuilt
+	// while rows are being drawn.
+	if an := ft.anim; an != nil {
+		if end := an.start.Add(treeAnimDuration); !gtx.Now.Before(end) {
+			ft.finishAnim()
+			n = len(ft.visible)
+		} else {
+			// Wake up for the end even if no animated row is drawn.
+			gtx.Execute(op.InvalidateCmd{At: end})
+		}
+	}
+
+	ft.handleKeys(gtx)
+	n = len(ft.visible)
+	focused := gtx.Focused(&ft.focusTag)
+
+	// Register the whole list area for keyboard focus; rows register their
+	// own pointer tags on top. Presses also reach this tag, which records
+	// where a context menu should open.
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &ft.focusTag, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if pe, ok := e.(pointer.Event); ok {
+			ft.pressAt = pe.Position.Round()
+		}
+	}
+	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, &ft.focusTag)
+
+	dims := fadingList(gtx, th, &ft.list, &ft.fade).Layout(gtx, n, func(gtx layout.Context, i int) layout.Dimensions {
 		if i >= len(ft.visible) {
 			return layout.Dimensions{}
 		}
@@ -127,6 +686,9 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 			}
 			switch pe.Kind {
 			case pointer.Enter:
+				if node.placeholder() {
+					continue
+				}
 				ft.hoveredIdx = i
 				ft.app.window.Invalidate()
 			case pointer.Leave:
@@ -135,10 +697,13 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 					ft.app.window.Invalidate()
 				}
 			case pointer.Press:
+				gtx.Execute(key.FocusCmd{Tag: &ft.focusTag})
+				if node.placeholder() {
+					continue
+				}
 				if pe.Buttons&pointer.ButtonPrimary != 0 {
 					if node.isDir {
-						ft.expanded[node.path] = !ft.expanded[node.path]
-						ft.rebuild()
+						ft.toggle(node.path)
 					} else {
 						ft.app.selectedPath = node.path
 						ft.app.confirmSwitch(node.path)
@@ -146,12 +711,37 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 					ft.app.window.Invalidate()
 				} else if pe.Buttons&pointer.ButtonSecondary != 0 {
 					ft.app.selectedPath = node.path
-					ft.app.promptNewFile()
+					ft.menu = &treeMenu{path: node.path, at: ft.pressAt}
 					ft.app.window.Invalidate()
 				}
 			}
 		}
 
+		// --- animated rows shrink to their current height and clip ---
+		if h := ft.animatedHeight(gtx, node, rowH); h < rowH {
+			rowSize.Y = h
+			defer clip.Rect{Max: rowSize}.Push(gtx.Ops).Pop()
+		}
+
+		// --- placeholder rows: a dimmed note, paging in more once in view ---
+		if node.placeholder() {
+			text := "Loading…"
+			if node.more > 0 {
+				text = fmt.Sprintf("%d more…", node.more)
+				ft.grow = node.path
+			}
+			layout.Inset{
+				Left: unit.Dp(float32(node.depth*14 + 8 + 14)),
+				Top:  unit.Dp(6),
+			}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Label(th, unit.Sp(12), text)
+				lbl.Color = mulAlpha(th.Palette.Fg, 130)
+				lbl.Font.Style = font.Italic
+				return lbl.Layout(gtx)
+			})
+			return layout.Dimensions{Size: rowSize}
+		}
+
 		// --- row background ---
 		isSelected := node.path == ft.app.currentFile || node.path == ft.app.selectedPath
 		var rowBg color.NRGBA
@@ -161,6 +751,10 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 			rowBg = mulAlpha(th.Palette.ContrastBg, 60)
 		}
 		paint.FillShape(gtx.Ops, rowBg, clip.Rect{Max: rowSize}.Op())
+		if focused && node.path == ft.app.selectedPath {
+			// Focus marker for keyboard navigation.
+			paint.FillShape(gtx.Ops, th.Palette.ContrastFg, clip.Rect{Max: image.Pt(gtx.Dp(3), rowSize.Y)}.Op())
+		}
 
 		// --- register event area for this row (single tag handles all pointer events) ---
 		rcStack := clip.Rect{Max: rowSize}.Push(gtx.Ops)
@@ -182,7 +776,7 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 					var arrow string
 					if node.isDir {
-						if ft.expanded[node.path] {
+						if ft.expanded[node.path] || ft.filtering() {
 							arrow = "▼ "
 						} else {
 							arrow = "▶ "
@@ -195,49 +789,313 @@ func (ft *FileTree) Layout(gtx layout.Context, th *material.Theme) layout.Dimens
 					return lbl.Layout(gtx)
 				}),
 				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-					lbl := material.Label(th, unit.Sp(13), node.name)
+					name := node.name
+					if ft.app.isDirty(node.path) {
+						name += " •"
+					}
+					lbl := material.Label(th, unit.Sp(13), name)
 					lbl.Color = fg
 					if node.isDir {
-						lbl.Font = font.Font{Weight: font.SemiBold}
+						lbl.Font.Weight = font.SemiBold
 					}
 					return lbl.Layout(gtx)
 				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layoutGitMarker(gtx, ft.app.gitStates[node.path])
+				}),
 			)
 		})
 
 		return layout.Dimensions{Size: rowSize}
 	})
+	if ft.grow != "" {
+		ft.showMore(ft.grow)
+		ft.grow = ""
+		ft.app.window.Invalidate()
+	}
+	ft.layoutMenu(gtx, th)
+	return dims
+}
+
+// ---------------------------------------------------------------------------
+// Row context menu
+// ---------------------------------------------------------------------------
+
+// treeMenu is the right-click menu for one row.
+type treeMenu struct {
+	path       string
+	at         image.Point // top-left corner, in list-area coordinates
+	btnNew     widget.Clickable
+	btnPin     widget.Clickable
+	btnSite    widget.Clickable
+	btnCombine widget.Clickable
+	btnPath    widget.Clickable
+	btnRelPath widget.Clickable
+}
+
+// layoutMenu draws the open context menu, if any, over the rows. A press
+// anywhere outside it closes it.
+func (ft *FileTree) layoutMenu(gtx layout.Context, th *material.Theme) {
+	m := ft.menu
+	if m == nil {
+		return
+	}
+	for {
+		e, ok := gtx.Event(pointer.Filter{Target: &ft.menu, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if _, ok := e.(pointer.Event); ok {
+			ft.menu = nil
+			return
+		}
+	}
+	if m.btnNew.Clicked(gtx) {
+		ft.menu = nil
+		ft.app.promptNewFile()
+		return
+	}
+	if m.btnPin.Clicked(gtx) {
+		ft.menu = nil
+		ft.app.togglePin(m.path)
+		return
+	}
+	if m.btnSite.Clicked(gtx) {
+		ft.menu = nil
+		ft.app.promptExportSite(m.path)
+		return
+	}
+	if m.btnCombine.Clicked(gtx) {
+		ft.menu = nil
+		ft.app.promptCombine(ft.app.collectNotes(m.path), filepath.Base(m.path))
+		return
+	}
+	if m.btnPath.Clicked(gtx) {
+		ft.menu = nil
+		writeClipboard(gtx, m.path)
+		ft.app.status = "Copied path: " + m.path
+		return
+	}
+	if m.btnRelPath.Clicked(gtx) {
+		ft.menu = nil
+		rel, err := filepath.Rel(ft.app.rootPath, m.path)
+		if err != nil {
+			ft.app.notifyError(err)
+			return
+		}
+		writeClipboard(gtx, rel)
+		ft.app.status = "Copied path: " + rel
+		return
+	}
+
+	scrim := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &ft.menu)
+	scrim.Pop()
+
+	pinLabel := "Pin"
+	if ft.app.isPinned(m.path) {
+		pinLabel = "Unpin"
+	}
+	item := func(c *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return material.Clickable(gtx, c, func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(5), Bottom: unit.Dp(5), Left: unit.Dp(10), Right: unit.Dp(10)}.Layout(gtx,
+					material.Label(th, unit.Sp(13), label).Layout)
+			})
+		})
+	}
+
+	rec := op.Record(gtx.Ops)
+	mgtx := gtx
+	mgtx.Constraints = layout.Constraints{Min: image.Pt(gtx.Dp(140), 0), Max: gtx.Constraints.Max}
+	items := []layout.FlexChild{
+		item(&m.btnNew, "New File…"),
+		item(&m.btnPin, pinLabel),
+		item(&m.btnPath, "Copy Path"),
+		item(&m.btnRelPath, "Copy Relative Path"),
+	}
+	if info, err := os.Stat(m.path); err == nil && info.IsDir() {
+		items = append(items, item(&m.btnSite, "Export as Site…"), item(&m.btnCombine, "Combine into Document…"))
+	}
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(mgtx, items...)
+	call := rec.Stop()
+
+	// Keep the menu inside the tree.
+	pos := m.at
+	pos.X = max(0, min(pos.X, gtx.Constraints.Max.X-dims.Size.X))
+	pos.Y = max(0, min(pos.Y, gtx.Constraints.Max.Y-dims.Size.Y))
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	rect := image.Rectangle{Max: dims.Size}
+	paint.FillShape(gtx.Ops, mulAlpha(th.Palette.Fg, 60), clip.Rect(rect.Inset(-1)).Op())
+	paint.FillShape(gtx.Ops, th.Palette.Bg, clip.Rect(rect).Op())
+	call.Add(gtx.Ops)
+}
+
+// handleKeys moves the selection with the arrow keys while the tree has
+// focus: Up/Down step through rows, Right expands a folder or enters it,
+// Left collapses a folder or jumps to its parent, and Enter opens the row.
+func (ft *FileTree) handleKeys(gtx layout.Context) {
+	for {
+		e, ok := gtx.Event(
+			key.FocusFilter{Target: &ft.focusTag},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameUpArrow},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameDownArrow},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameLeftArrow},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameRightArrow},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameReturn},
+			key.Filter{Focus: &ft.focusTag, Name: key.NameEnter},
+		)
+		if !ok {
+			break
+		}
+		ke, ok := e.(key.Event)
+		if !ok || ke.State != key.Press || len(ft.visible) == 0 {
+			continue
+		}
+		cur := ft.cursorIndex()
+		if cur < 0 {
+			ft.moveCursor(0)
+			continue
+		}
+		node := ft.visible[cur]
+		switch ke.Name {
+		case key.NameUpArrow:
+			ft.moveCursor(ft.nextRow(cur, -1))
+		case key.NameDownArrow:
+			if cur+1 < len(ft.visible) && ft.visible[cur+1].more > 0 {
+				ft.showMore(ft.visible[cur+1].path)
+			}
+			ft.moveCursor(ft.nextRow(cur, 1))
+		case key.NameRightArrow:
+			if node.isDir && !ft.expanded[node.path] {
+				ft.toggle(node.path)
+			} else if node.isDir && cur+1 < len(ft.visible) && ft.visible[cur+1].depth > node.depth && !ft.visible[cur+1].placeholder() {
+				ft.moveCursor(cur + 1)
+			}
+		case key.NameLeftArrow:
+			if node.isDir && ft.expanded[node.path] {
+				ft.toggle(node.path)
+				break
+			}
+			for i := cur - 1; i >= 0; i-- {
+				if ft.visible[i].depth < node.depth {
+					ft.moveCursor(i)
+					break
+				}
+			}
+		case key.NameReturn, key.NameEnter:
+			if node.isDir {
+				ft.toggle(node.path)
+			} else {
+				ft.app.confirmSwitch(node.path)
+			}
+		}
+		ft.app.window.Invalidate()
+	}
+}
+
+// cursorIndex returns the row index of the selected path, or -1.
+func (ft *FileTree) cursorIndex() int {
+	for i, n := range ft.visible {
+		if n.path == ft.app.selectedPath {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextRow returns the index of the first real row from cur in direction
+// step, skipping placeholders, or cur if there is none.
+func (ft *FileTree) nextRow(cur, step int) int {
+	for i := cur + step; i >= 0 && i < len(ft.visible); i += step {
+		if !ft.visible[i].placeholder() {
+			return i
+		}
+	}
+	return cur
+}
+
+// moveCursor selects row i (clamped) and scrolls it into view.
+func (ft *FileTree) moveCursor(i int) {
+	i = max(0, min(i, len(ft.visible)-1))
+	ft.app.selectedPath = ft.visible[i].path
+	pos := &ft.list.Position
+	if i < pos.First {
+		pos.First, pos.Offset = i, 0
+	} else if pos.Count > 0 && i >= pos.First+pos.Count-1 {
+		pos.First, pos.Offset = i-pos.Count+2, 0
+	}
 }
 
 // ---------------------------------------------------------------------------
-// listDir — shared by FileTree and actions
+// Folder listings
 // ---------------------------------------------------------------------------
 
-// listDir returns direct children of path: dirs first (alpha), then .md files
-// (alpha). Hidden entries (name starts with ".") are excluded.
-func (a *App) listDir(path string) []string {
-	entries, err := os.ReadDir(path)
+// isNoteFile reports whether name has a note extension: .md, or the one
+// configured for new files.
+func (a *App) isNoteFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".md" || ext == strings.ToLower(a.newFileExt())
+}
+
+// readTreeDir returns the children of dir, skipping dotfiles, with their
+// modification times when mtimes is set. It runs off the UI goroutine, so it
+// touches nothing but the filesystem; a folder that cannot be read is empty.
+func readTreeDir(dir string, mtimes bool) []treeEntry {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil
 	}
-
-	var dirs, files []string
+	var list []treeEntry
 	for _, e := range entries {
 		if strings.HasPrefix(e.Name(), ".") {
 			continue
 		}
-		full := filepath.Join(path, e.Name())
-		if e.IsDir() {
-			dirs = append(dirs, full)
-		} else if strings.ToLower(filepath.Ext(e.Name())) == ".md" {
-			files = append(files, full)
+		en := treeEntry{path: filepath.Join(dir, e.Name()), isDir: e.IsDir()}
+		if mtimes {
+			if info, err := e.Info(); err == nil {
+				en.mtime = info.ModTime()
+			}
 		}
+		list = append(list, en)
 	}
+	return list
+}
 
-	sort.Slice(dirs, func(i, j int) bool { return dirs[i] < dirs[j] })
-	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+// listEntries keeps the dirs and note files of a folder's entries that are
+// not ignored, ordered by the tree's sort settings.
+func (a *App) listEntries(entries []treeEntry) []treeEntry {
+	list := make([]treeEntry, 0, len(entries))
+	for _, en := range entries {
+		if !en.isDir && !a.isNoteFile(en.path) {
+			continue
+		}
+		if a.ignored(en.path, en.isDir) {
+			continue
+		}
+		list = append(list, en)
+	}
 
-	return append(dirs, files...)
+	sort.SliceStable(list, func(i, j int) bool {
+		x, y := list[i], list[j]
+		if a.cfg.DirsFirst && x.isDir != y.isDir {
+			return x.isDir
+		}
+		switch a.cfg.TreeSort {
+		case sortNameDesc:
+			return x.path > y.path
+		case sortModifiedNewest:
+			if !x.mtime.Equal(y.mtime) {
+				return x.mtime.After(y.mtime)
+			}
+		case sortModifiedOldest:
+			if !x.mtime.Equal(y.mtime) {
+				return x.mtime.Before(y.mtime)
+			}
+		}
+		return x.path < y.path
+	})
+	return list
 }
 
 // ---------------------------------------------------------------------------
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/system"
+	"gioui.org/layout"
+)
+
+// ---------------------------------------------------------------------------
+// Vim keymap (Config.Keymap == "vim")
+//
+// Normal mode makes the editor read-only, so typed characters are not
+// inserted, and shows the cursor as a one-rune selection since read-only
+// editors draw no caret. Keys are intercepted before the editor sees them,
+// like Tab in layoutEditor. Supported: h j k l w b 0 $ gg G motions; x, p, P;
+// i a I A o O to insert; d y c with a motion or doubled for whole lines; and
+// :w :q :wq :q! on the command line. Undo is Ctrl+Z in insert mode.
+// ---------------------------------------------------------------------------
+
+type vimMode int
+
+const (
+	vimNormal vimMode = iota
+	vimInsert
+)
+
+func (m vimMode) label() string {
+	if m == vimInsert {
+		return "-- INSERT --"
+	}
+	return "-- NORMAL --"
+}
+
+// keymapState is the vim or emacs keymap state between key presses. The
+// register doubles as the emacs kill ring.
+type keymapState struct {
+	mode     vimMode
+	pending  string // operator (d, y, c) or "g" waiting for the next key
+	register string // last yank, delete or kill
+	linewise bool   // register holds whole lines
+
+	// Emacs mark (C-Space); while active, motions extend the selection.
+	mark       int
+	markActive bool
+	// Caret and document length after the last C-k; a C-k that finds both
+	// unchanged appends to the register, like repeated kills in emacs.
+	killAt, killLen int
+}
+
+func (a *App) vimEnabled() bool {
+	return strings.EqualFold(a.cfg.Keymap, "vim")
+}
+
+// vimKeys are the key names the normal-mode interceptor claims.
+var vimKeys = []key.Name{
+	"H", "J", "K", "L", "W", "B", "G", "D", "Y", "C", "P", "X",
+	"I", "A", "O", "0", "4", "$", ";", ":",
+}
+
+// handleVimKeys runs the vim keymap for this frame. It must run before the
+// editor's own Update so normal-mode keys never reach it.
+func (a *App) handleVimKeys(gtx layout.Context) {
+	if !a.vimEnabled() {
+		a.editor.ReadOnly = a.readOnly
+		return
+	}
+	v := &a.keymap
+	a.editor.ReadOnly = a.readOnly || v.mode == vimNormal
+
+	filters := []event.Filter{key.Filter{Focus: &a.editor, Name: key.NameEscape}}
+	if v.mode == vimNormal {
+		for _, name := range vimKeys {
+			filters = append(filters, key.Filter{Focus: &a.editor, Name: name, Optional: key.ModShift})
+		}
+	}
+	for {
+		e, ok := gtx.Event(filters...)
+		if !ok {
+			break
+		}
+		ke, ok := e.(key.Event)
+		if !ok || ke.State != key.Press {
+			continue
+		}
+		if ke.Name == key.NameEscape {
+			a.vimEscape(gtx)
+			continue
+		}
+		a.vimNormalKey(vimChar(ke))
+	}
+
+	// A mouse click collapses the block cursor; put it back. Only touch the
+	// selection when it differs, since SetCaret scrolls the caret into view.
+	if v.mode == vimNormal && a.editor.SelectionLen() == 0 {
+		runes := []rune(a.editor.Text())
+		pos, _ := a.editor.Selection()
+		if start, end := vimCursor(runes, pos); start != pos || end != pos {
+			a.editor.SetCaret(start, end)
+		}
+	}
+}
+
+// vimChar turns a key event into the character vim would see.
+func vimChar(ke key.Event) string {
+	shift := ke.Modifiers.Contain(key.ModShift)
+	switch ke.Name {
+	case "4":
+		if shift {
+			return "$"
+		}
+	case ";":
+		if shift {
+			return ":"
+		}
+	}
+	n := string(ke.Name)
+	if len(n) == 1 && n[0] >= 'A' && n[0] <= 'Z' && !shift {
+		return strings.ToLower(n)
+	}
+	return n
+}
+
+// vimEscape leaves insert mode, or cancels a pending operator. In normal
+// mode with nothing pending it falls back to Escape's usual meaning, which
+// handleKeys leaves to it while the editor is focused.
+func (a *App) vimEscape(gtx layout.Context) {
+	v := &a.keymap
+	switch {
+	case v.mode == vimInsert:
+		v.mode = vimNormal
+		a.editor.ReadOnly = true
+		runes := []rune(a.editor.Text())
+		pos, _ := a.orderedSelection()
+		if pos > lineStart(runes, pos) {
+			pos-- // vim steps back over the last inserted character
+		}
+		a.vimSetCursor(runes, pos)
+	case v.pending != "":
+		v.pending = ""
+	case a.hasExtraCarets():
+		a.dropCarets()
+	case a.define != nil:
+		a.define = nil
+	case a.showHelp:
+		a.toggleHelp()
+	case a.showStats:
+		a.toggleStats()
+	case a.showRecent:
+		a.toggleRecent()
+	case a.focusMode:
+		a.toggleFocusMode(gtx)
+	}
+}
+
+// vimSetCursor shows the normal-mode block cursor on the rune at pos, kept
+// off the line-ending newline.
+func (a *App) vimSetCursor(runes []rune, pos int) {
+	a.editor.SetCaret(vimCursor(runes, pos))
+}
+
+// vimCursor returns the selection covering the rune at pos, or an empty one
+// on an empty line.
+func vimCursor(runes []rune, pos int) (start, end int) {
+	pos = max(0, min(pos, len(runes)))
+	if pos > lineStart(runes, pos) && (pos == len(runes) || runes[pos] == '\n') {
+		pos--
+	}
+	if pos < len(runes) && runes[pos] != '\n' {
+		return pos, pos + 1
+	}
+	return pos, pos
+}
+
+// vimInsertAt switches to insert mode with the caret at pos.
+func (a *App) vimInsertAt(pos int) {
+	a.keymap.mode = vimInsert
+	a.editor.ReadOnly = false
+	a.editor.SetCaret(pos, pos)
+}
+
+// vimNormalKey handles one normal-mode key.
+func (a *App) vimNormalKey(k string) {
+	v := &a.keymap
+	runes := []rune(a.editor.Text())
+	pos, _ := a.orderedSelection()
+	ls, le := lineStart(runes, pos), lineEnd(runes, pos)
+
+	switch pending := v.pending; {
+	case pending == "g":
+		v.pending = ""
+		if k == "g" {
+			a.vimSetCursor(runes, 0)
+		}
+		return
+	case len(pending) == 2: // "dg", "yg" or "cg"
+		v.pending = ""
+		if k == "g" {
+			a.vimMotionOp(pending[:1], runes, pos, "gg")
+		}
+		return
+	case pending != "":
+		v.pending = ""
+		switch k {
+		case pending:
+			a.vimLineOp(pending, runes, ls, le)
+		case "g":
+			v.pending = pending + "g"
+		default:
+			a.vimMotionOp(pending, runes, pos, k)
+		}
+		return
+	}
+
+	switch k {
+	case "d", "c", "x", "p", "P", "i", "a", "I", "A", "o", "O":
+		if a.denyReadOnly() {
+			return
+		}
+	}
+	switch k {
+	case "d", "y", "c":
+		v.pending = k
+	case "g":
+		v.pending = "g"
+	case "x":
+		if pos < le {
+			v.register, v.linewise = string(runes[pos:pos+1]), false
+			a.replaceRange(pos, pos+1, "")
+			a.vimSetCursor([]rune(a.editor.Text()), pos)
+		}
+	case "p", "P":
+		a.vimPut(runes, pos, ls, le, k == "P")
+	case "i":
+		a.vimInsertAt(pos)
+	case "a":
+		a.vimInsertAt(min(pos+1, le))
+	case "I":
+		a.vimInsertAt(firstNonBlank(runes, ls, le))
+	case "A":
+		a.vimInsertAt(le)
+	case "o":
+		a.replaceRange(le, le, "\n")
+		a.vimInsertAt(le + 1)
+	case "O":
+		a.replaceRange(ls, ls, "\n")
+		a.vimInsertAt(ls)
+	case ":":
+		a.promptVimCommand()
+	default:
+		if target, ok := vimMotion(runes, pos, k); ok {
+			a.vimSetCursor(runes, target)
+		}
+	}
+}
+
+// vimMotion returns where motion k moves the cursor from pos.
+func vimMotion(runes []rune, pos int, k string) (int, bool) {
+	ls, le := lineStart(runes, pos), lineEnd(runes, pos)
+	switch k {
+	case "h":
+		return max(pos-1, ls), true
+	case "l":
+		return min(pos+1, max(le-1, ls)), true
+	case "0":
+		return ls, true
+	case "$":
+		return max(le-1, ls), true
+	case "w":
+		return nextWordStart(runes, pos), true
+	case "b":
+		return prevWordStart(runes, pos), true
+	case "j":
+		if le == len(runes) {
+			return pos, true
+		}
+		next := le + 1
+		return min(next+pos-ls, lineEnd(runes, next)), true
+	case "k":
+		if ls == 0 {
+			return pos, true
+		}
+		prev := lineStart(runes, ls-1)
+		return min(prev+pos-ls, ls-1), true
+	case "G":
+		return lineStart(runes, len(runes)), true
+	case "gg":
+		return 0, true
+	}
+	return pos, false
+}
+
+// vimLineOp applies dd, yy or cc to the line [ls, le).
+func (a *App) vimLineOp(op string, runes []rune, ls, le int) {
+	v := &a.keymap
+	v.register, v.linewise = string(runes[ls:le])+"\n", true
+	switch op {
+	case "d":
+		start, end := ls, le
+		if end < len(runes) {
+			end++ // take the newline
+		} else if start > 0 {
+			start-- // last line: take the newline before it
+		}
+		a.replaceRange(start, end, "")
+		a.vimSetCursor([]rune(a.editor.Text()), start)
+	case "c":
+		a.replaceRange(ls, le, "")
+		a.vimInsertAt(ls)
+	case "y":
+		a.vimSetCursor(runes, ls)
+	}
+}
+
+// vimMotionOp applies operator op over the text between pos and the target of
+// motion k. j, k, G and gg act on whole lines, $ includes the last character,
+// and the rest are exclusive.
+func (a *App) vimMotionOp(op string, runes []rune, pos int, k string) {
+	v := &a.keymap
+	target, ok := vimMotion(runes, pos, k)
+	if !ok {
+		return
+	}
+	start, end := min(pos, target), max(pos, target)
+	switch k {
+	case "j", "k", "G", "gg":
+		start, end = lineStart(runes, start), lineEnd(runes, end)
+		v.register, v.linewise = string(runes[start:end])+"\n", true
+		if op == "y" {
+			a.vimSetCursor(runes, start)
+			return
+		}
+		if op == "d" {
+			if end < len(runes) {
+				end++
+			} else if start > 0 {
+				start--
+			}
+		}
+	default:
+		if k == "$" {
+			end = lineEnd(runes, pos)
+		}
+		if k == "w" && op == "c" {
+			// cw changes to the end of the word, not up to the next one.
+			for end > start && unicode.IsSpace(runes[end-1]) {
+				end--
+			}
+		}
+		v.register, v.linewise = string(runes[start:end]), false
+		if op == "y" {
+			a.vimSetCursor(runes, start)
+			return
+		}
+	}
+	a.replaceRange(start, end, "")
+	if op == "c" {
+		a.vimInsertAt(start)
+		return
+	}
+	a.vimSetCursor([]rune(a.editor.Text()), start)
+}
+
+// vimPut pastes the register after (p) or before (P) the cursor.
+func (a *App) vimPut(runes []rune, pos, ls, le int, before bool) {
+	v := &a.keymap
+	if v.register == "" {
+		return
+	}
+	if v.linewise {
+		text := strings.TrimSuffix(v.register, "\n")
+		if before {
+			a.replaceRange(ls, ls, text+"\n")
+			a.vimSetCursor([]rune(a.editor.Text()), ls)
+			return
+		}
+		a.replaceRange(le, le, "\n"+text)
+		a.vimSetCursor([]rune(a.editor.Text()), le+1)
+		return
+	}
+	at := pos
+	if !before && pos < le {
+		at++
+	}
+	a.replaceRange(at, at, v.register)
+	a.vimSetCursor([]rune(a.editor.Text()), at+len([]rune(v.register))-1)
+}
+
+// promptVimCommand asks for an ex command: w, q, wq, x or q!.
+func (a *App) promptVimCommand() {
+	a.showInputModal(":", "Command (w, q, wq, q!):", func(cmd string) {
+		switch strings.TrimSpace(cmd) {
+		case "w":
+			a.saveFile()
+		case "wq", "x":
+			a.saveFile()
+			if !a.modified {
+				a.window.Perform(system.ActionClose)
+			}
+		case "q":
+			if a.modified {
+				a.status = "No write since last change (add ! to override)"
+				return
+			}
+			a.window.Perform(system.ActionClose)
+		case "q!":
+			a.window.Perform(system.ActionClose)
+		default:
+			a.status = "Not an editor command: " + cmd
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Word helpers
+// ---------------------------------------------------------------------------
+
+// runeClass groups runes for word motions: 0 space, 1 word, 2 punctuation.
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+// nextWordStart returns the start of the word after pos.
+func nextWordStart(runes []rune, pos int) int {
+	if pos >= len(runes) {
+		return pos
+	}
+	c := runeClass(runes[pos])
+	for pos < len(runes) && c != 0 && runeClass(runes[pos]) == c {
+		pos++
+	}
+	for pos < len(runes) && runeClass(runes[pos]) == 0 {
+		pos++
+	}
+	return pos
+}
+
+// prevWordStart returns the start of the word before pos.
+func prevWordStart(runes []rune, pos int) int {
+	for pos > 0 && runeClass(runes[pos-1]) == 0 {
+		pos--
+	}
+	if pos == 0 {
+		return 0
+	}
+	c := runeClass(runes[pos-1])
+	for pos > 0 && runeClass(runes[pos-1]) == c {
+		pos--
+	}
+	return pos
+}
+
+// firstNonBlank returns the offset of the first non-space rune in [ls, le).
+func firstNonBlank(runes []rune, ls, le int) int {
+	for ls < le && (runes[ls] == ' ' || runes[ls] == '\t') {
+		ls++
+	}
+	return ls
+}